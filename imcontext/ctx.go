@@ -3,8 +3,7 @@ package imcontext
 import "context"
 
 var (
-	mapper = []struct {
-	}{
+	mapper = []any{
 		Operation{},
 		OpUserID{},
 		OpUserPlatform{},
@@ -28,9 +27,14 @@ type ConnID struct{}
 type RemoteAddr struct{}
 type TriggerID struct{}
 
-func WithMustInfoCtx(ctx context.Context, value []struct{}) context.Context {
+// WithMustInfoCtx 按 Operation, OpUserID, OpUserPlatform, ConnID, RemoteAddr,
+// TriggerID 的顺序设置 value 中的值, 多余的值被忽略
+func WithMustInfoCtx(ctx context.Context, value []string) context.Context {
 	nCtx := ctx
 	for i, v := range value {
+		if i >= len(mapper) {
+			break
+		}
 		nCtx = context.WithValue(nCtx, mapper[i], v)
 	}
 	return nCtx
@@ -48,44 +52,44 @@ func WithConnID(ctx context.Context, value string) context.Context {
 	return context.WithValue(ctx, ConnID{}, value)
 }
 
+func WithOperation(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, Operation{}, value)
+}
+
+func WithRemoteAddr(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, RemoteAddr{}, value)
+}
+
+func WithTriggerID(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, TriggerID{}, value)
+}
+
 func GetOperation(ctx context.Context) string {
-	if v := ctx.Value(Operation{}); v != nil {
-		return v.(string)
-	}
-	return ""
+	v, _ := ctx.Value(Operation{}).(string)
+	return v
 }
 
 func GetOpUserID(ctx context.Context) string {
-	if v := ctx.Value(OpUserID{}); v != nil {
-		return v.(string)
-	}
-	return ""
+	v, _ := ctx.Value(OpUserID{}).(string)
+	return v
 }
 
 func GetConnID(ctx context.Context) string {
-	if v := ctx.Value(ConnID{}); v != nil {
-		return v.(string)
-	}
-	return ""
+	v, _ := ctx.Value(ConnID{}).(string)
+	return v
 }
 
 func GetTriggerID(ctx context.Context) string {
-	if v := ctx.Value(TriggerID{}); v != nil {
-		return v.(string)
-	}
-	return ""
+	v, _ := ctx.Value(TriggerID{}).(string)
+	return v
 }
 
 func GetOpUserPlatform(ctx context.Context) string {
-	if v := ctx.Value(OpUserPlatform{}); v != nil {
-		return v.(string)
-	}
-	return ""
+	v, _ := ctx.Value(OpUserPlatform{}).(string)
+	return v
 }
 
 func GetRemoteAddr(ctx context.Context) string {
-	if v := ctx.Value(RemoteAddr{}); v != nil {
-		return v.(string)
-	}
-	return ""
+	v, _ := ctx.Value(RemoteAddr{}).(string)
+	return v
 }
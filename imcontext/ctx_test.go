@@ -0,0 +1,29 @@
+package imcontext
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithMustInfoCtx(t *testing.T) {
+	ctx := WithMustInfoCtx(context.Background(), []string{"op-1", "u-1", "ios", "c-1", "127.0.0.1", "t-1", "extra"})
+	got := []string{GetOperation(ctx), GetOpUserID(ctx), GetOpUserPlatform(ctx), GetConnID(ctx), GetRemoteAddr(ctx), GetTriggerID(ctx)}
+	want := []string{"op-1", "u-1", "ios", "c-1", "127.0.0.1", "t-1"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("value %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	ctx = WithMustInfoCtx(context.Background(), []string{"op-2"})
+	if GetOperation(ctx) != "op-2" || GetOpUserID(ctx) != "" {
+		t.Errorf("partial values = %q, %q", GetOperation(ctx), GetOpUserID(ctx))
+	}
+}
+
+func TestGetNonString(t *testing.T) {
+	ctx := context.WithValue(context.Background(), Operation{}, struct{}{})
+	if got := GetOperation(ctx); got != "" {
+		t.Errorf("operation = %q, want empty", got)
+	}
+}
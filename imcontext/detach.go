@@ -0,0 +1,28 @@
+package imcontext
+
+import (
+	"context"
+	"time"
+)
+
+// Detach 返回一个不继承 ctx 取消信号和截止时间的新 context,
+// 但保留 ctx 上所有已知的 imcontext 值, 用于在请求结束后继续执行的后台任务。
+func Detach(ctx context.Context) context.Context {
+	nCtx := context.Background()
+	for _, key := range mapper {
+		if v := ctx.Value(key); v != nil {
+			nCtx = context.WithValue(nCtx, key, v)
+		}
+	}
+	return nCtx
+}
+
+// DetachWithTimeout 同 Detach, 并为新 context 设置超时时间
+func DetachWithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(Detach(ctx), timeout)
+}
+
+// DetachWithDeadline 同 Detach, 并为新 context 设置截止时间
+func DetachWithDeadline(ctx context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	return context.WithDeadline(Detach(ctx), deadline)
+}
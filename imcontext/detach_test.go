@@ -0,0 +1,41 @@
+package imcontext
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDetach(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = WithOperation(ctx, "op-1")
+	ctx = WithOpUserID(ctx, "u-1")
+	ctx = WithRemoteAddr(ctx, "127.0.0.1")
+	cancel()
+
+	nCtx := Detach(ctx)
+	if err := nCtx.Err(); err != nil {
+		t.Fatalf("detached context inherited cancellation: %v", err)
+	}
+	if got := GetOperation(nCtx); got != "op-1" {
+		t.Errorf("operation = %q, want op-1", got)
+	}
+	if got := GetOpUserID(nCtx); got != "u-1" {
+		t.Errorf("opUserID = %q, want u-1", got)
+	}
+	if got := GetRemoteAddr(nCtx); got != "127.0.0.1" {
+		t.Errorf("remoteAddr = %q, want 127.0.0.1", got)
+	}
+	if got := GetConnID(nCtx); got != "" {
+		t.Errorf("connID = %q, want empty", got)
+	}
+
+	tCtx, tCancel := DetachWithTimeout(ctx, time.Hour)
+	defer tCancel()
+	if _, ok := tCtx.Deadline(); !ok {
+		t.Error("DetachWithTimeout did not set a deadline")
+	}
+	if got := GetOperation(tCtx); got != "op-1" {
+		t.Errorf("operation = %q, want op-1", got)
+	}
+}
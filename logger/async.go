@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// OverflowBlock makes writers wait for free space in the buffer.
+	OverflowBlock = "block"
+	// OverflowDropOldest discards the oldest buffered entry to make room.
+	OverflowDropOldest = "drop_oldest"
+	// OverflowDrop discards the new entry.
+	OverflowDrop = "drop"
+)
+
+type AsyncConfig struct {
+	BufferSize    int           `json:"bufferSize" yaml:"bufferSize" default:"4096" description:"缓冲的日志条数"`
+	FlushInterval time.Duration `json:"flushInterval" yaml:"flushInterval" default:"1s" description:"定时刷盘间隔"`
+	Overflow      string        `json:"overflow" yaml:"overflow" default:"block" description:"缓冲区满时的策略: block/drop_oldest/drop"`
+}
+
+func (c *AsyncConfig) UnmarshalJSON(data []byte) error {
+	type plain AsyncConfig
+	return json.Unmarshal(data, &struct {
+		*plain
+		FlushInterval *duration `json:"flushInterval"`
+	}{plain: (*plain)(c), FlushInterval: (*duration)(&c.FlushInterval)})
+}
+
+// AsyncWriter buffers writes in a bounded queue and writes them to the
+// underlying WriteSyncer from a single goroutine. Sync waits until every
+// entry queued before it has been written and synced.
+type AsyncWriter struct {
+	ws       zapcore.WriteSyncer
+	overflow string
+	interval time.Duration
+
+	mu      sync.RWMutex
+	closed  bool
+	ch      chan []byte
+	flushCh chan chan error
+	done    chan struct{}
+	stopped chan struct{}
+	dropped atomic.Uint64
+	// closeErr is the error of the final drain, set before stopped is
+	// closed.
+	closeErr error
+
+	droppedMetric CounterVec
+	metricLabels  []string
+}
+
+var _ zapcore.WriteSyncer = (*AsyncWriter)(nil)
+
+func NewAsyncWriter(ws zapcore.WriteSyncer, cfg AsyncConfig) (*AsyncWriter, error) {
+	switch cfg.Overflow {
+	case "":
+		cfg.Overflow = OverflowBlock
+	case OverflowBlock, OverflowDropOldest, OverflowDrop:
+	default:
+		return nil, fmt.Errorf("logger: unknown async overflow policy %q", cfg.Overflow)
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 4096
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	w := &AsyncWriter{
+		ws:       ws,
+		overflow: cfg.Overflow,
+		interval: cfg.FlushInterval,
+		ch:       make(chan []byte, cfg.BufferSize),
+		flushCh:  make(chan chan error),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	switch w.overflow {
+	case OverflowDrop:
+		select {
+		case w.ch <- b:
+		default:
+			w.drop()
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case w.ch <- b:
+				return len(p), nil
+			default:
+			}
+			select {
+			case <-w.ch:
+				w.drop()
+			default:
+			}
+		}
+	default:
+		w.ch <- b
+	}
+	return len(p), nil
+}
+
+// Sync blocks until the entries buffered so far are written and the
+// underlying WriteSyncer is synced. It returns the first error of the writes
+// and syncs since the previous Sync.
+func (w *AsyncWriter) Sync() error {
+	req := make(chan error)
+	select {
+	case w.flushCh <- req:
+		return <-req
+	case <-w.stopped:
+	}
+	return nil
+}
+
+// Close stops accepting entries and drains the buffer, it returns the
+// errors not reported by Sync yet. It does not close the underlying
+// WriteSyncer.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.done)
+	<-w.stopped
+	return w.closeErr
+}
+
+// Dropped returns the number of entries discarded by the overflow policy.
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// countDropped also reports the discarded entries to c with labelValues, it
+// must be called before the first Write.
+func (w *AsyncWriter) countDropped(c CounterVec, labelValues ...string) {
+	w.droppedMetric, w.metricLabels = c, labelValues
+}
+
+func (w *AsyncWriter) drop() {
+	w.dropped.Add(1)
+	if w.droppedMetric != nil {
+		w.droppedMetric.Inc(w.metricLabels...)
+	}
+}
+
+// Buffered returns the number of entries waiting to be written.
+func (w *AsyncWriter) Buffered() int {
+	return len(w.ch)
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	// err is the first error since the last Sync
+	var err error
+	for {
+		select {
+		case b := <-w.ch:
+			if _, werr := w.ws.Write(b); err == nil {
+				err = werr
+			}
+		case <-ticker.C:
+			if serr := w.ws.Sync(); err == nil {
+				err = serr
+			}
+		case req := <-w.flushCh:
+			req <- w.drain(err)
+			err = nil
+		case <-w.done:
+			w.closeErr = w.drain(err)
+			return
+		}
+	}
+}
+
+// drain writes the buffered entries and syncs the underlying WriteSyncer,
+// it returns err or else the first error of the writes and the sync.
+func (w *AsyncWriter) drain(err error) error {
+	for {
+		select {
+		case b := <-w.ch:
+			if _, werr := w.ws.Write(b); err == nil {
+				err = werr
+			}
+		default:
+			if serr := w.ws.Sync(); err == nil {
+				err = serr
+			}
+			return err
+		}
+	}
+}
+
+// syncOnLevelCore syncs ws after writing an entry at or above level, so that
+// errors reach the disk right away even when ws is asynchronous.
+type syncOnLevelCore struct {
+	zapcore.Core
+	ws    zapcore.WriteSyncer
+	level zapcore.Level
+}
+
+func (c *syncOnLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &syncOnLevelCore{Core: c.Core.With(fields), ws: c.ws, level: c.level}
+}
+
+func (c *syncOnLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syncOnLevelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if err := c.Core.Write(ent, fields); err != nil {
+		return err
+	}
+	if ent.Level >= c.level {
+		return c.ws.Sync()
+	}
+	return nil
+}
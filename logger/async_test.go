@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type gatedWriter struct {
+	mu   sync.Mutex
+	buf  bytes.Buffer
+	gate chan struct{}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gatedWriter) Sync() error { return nil }
+
+func (w *gatedWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriterDrainOnClose(t *testing.T) {
+	ws := &gatedWriter{gate: make(chan struct{})}
+	close(ws.gate)
+	aw, err := NewAsyncWriter(ws, AsyncConfig{BufferSize: 16, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		_, _ = aw.Write([]byte("x\n"))
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(ws.String(), "x\n"); got != 10 {
+		t.Errorf("wrote %d entries after Close, want 10", got)
+	}
+	if _, err := aw.Write([]byte("late\n")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestAsyncWriterOverflow(t *testing.T) {
+	for _, policy := range []string{OverflowDrop, OverflowDropOldest} {
+		t.Run(policy, func(t *testing.T) {
+			ws := &gatedWriter{gate: make(chan struct{})}
+			aw, err := NewAsyncWriter(ws, AsyncConfig{BufferSize: 2, FlushInterval: time.Hour, Overflow: policy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			reg := NewMemoryRegistry()
+			aw.countDropped(reg.Counter(MetricEntriesDropped, "", "module", "overflow"), "mall", policy)
+			// the first entry is taken by the writer goroutine and blocks on
+			// the gate, the next two fill the buffer.
+			_, _ = aw.Write([]byte("0\n"))
+			for aw.Buffered() != 0 {
+				time.Sleep(time.Millisecond)
+			}
+			for _, s := range []string{"1\n", "2\n", "3\n", "4\n"} {
+				_, _ = aw.Write([]byte(s))
+			}
+			if got := aw.Dropped(); got != 2 {
+				t.Errorf("dropped = %d, want 2", got)
+			}
+			if got := reg.CounterValue(MetricEntriesDropped, "mall", policy); got != 2 {
+				t.Errorf("dropped metric = %v, want 2", got)
+			}
+			close(ws.gate)
+			_ = aw.Close()
+			want := "0\n1\n2\n"
+			if policy == OverflowDropOldest {
+				want = "0\n3\n4\n"
+			}
+			if got := ws.String(); got != want {
+				t.Errorf("written = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+type countingSyncer struct {
+	zapcore.WriteSyncer
+	syncs int
+}
+
+func (c *countingSyncer) Sync() error {
+	c.syncs++
+	return nil
+}
+
+func TestSyncOnLevelCore(t *testing.T) {
+	ws := &countingSyncer{WriteSyncer: zapcore.AddSync(&bytes.Buffer{})}
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	core := &syncOnLevelCore{Core: zapcore.NewCore(enc, ws, zapcore.DebugLevel), ws: ws, level: zapcore.ErrorLevel}
+	for _, lvl := range []zapcore.Level{zapcore.InfoLevel, zapcore.ErrorLevel} {
+		ent := zapcore.Entry{Level: lvl, Message: "m"}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+	if ws.syncs != 1 {
+		t.Errorf("synced %d times, want 1", ws.syncs)
+	}
+}
+
+type failingWriter struct {
+	writeErr, syncErr error
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) { return len(p), w.writeErr }
+func (w *failingWriter) Sync() error                 { return w.syncErr }
+
+func TestAsyncWriterErrors(t *testing.T) {
+	errDisk := errors.New("disk full")
+	ws := &failingWriter{writeErr: errDisk}
+	aw, err := NewAsyncWriter(ws, AsyncConfig{FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = aw.Write([]byte("x\n"))
+	if err := aw.Sync(); !errors.Is(err, errDisk) {
+		t.Errorf("Sync after a failed write = %v", err)
+	}
+	ws.writeErr = nil
+	if err := aw.Sync(); err != nil {
+		t.Errorf("Sync reported the error again: %v", err)
+	}
+
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	core := &syncOnLevelCore{Core: zapcore.NewCore(enc, aw, zapcore.DebugLevel), ws: aw, level: zapcore.ErrorLevel}
+	ws.syncErr = errDisk
+	if err := core.Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "boom"}, nil); !errors.Is(err, errDisk) {
+		t.Errorf("error level write = %v", err)
+	}
+
+	_, _ = aw.Write([]byte("y\n"))
+	if err := aw.Close(); !errors.Is(err, errDisk) {
+		t.Errorf("Close = %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/yunbaifan/pkg/imcontext"
+	"gorm.io/gorm"
+)
+
+// ErrAuditTampered is returned by VerifyAudit when the audit trail was
+// modified, reordered or truncated in the middle.
+var ErrAuditTampered = errors.New("logger: audit trail tampered")
+
+// AuditRecord is one line of the audit trail. Hash is the sha256 of the
+// record with an empty Hash, which includes the Hash of the previous record
+// as PrevHash, so changing any record breaks the chain after it.
+type AuditRecord struct {
+	Seq            uint64    `json:"seq"`
+	Time           time.Time `json:"time"`
+	Operation      string    `json:"operation"`
+	Table          string    `json:"table"`
+	SQL            string    `json:"sql"`
+	Rows           int64     `json:"rows"`
+	Error          string    `json:"error,omitempty"`
+	TxID           string    `json:"txID,omitempty"`
+	OperationID    string    `json:"operationID,omitempty"`
+	OpUserID       string    `json:"opUserID,omitempty"`
+	OpUserPlatform string    `json:"opUserPlatform,omitempty"`
+	RemoteAddr     string    `json:"remoteAddr,omitempty"`
+	PrevHash       string    `json:"prevHash"`
+	Hash           string    `json:"hash"`
+}
+
+func (r AuditRecord) hash() (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// AuditConfig configures an AuditPlugin. The records are written to
+// Dir/Prefix.log, rotated as described by the embedded RotateConfig. The
+// trail is append-only, so the retention limits MaxBackups, MaxAge and
+// MaxTotalSize are rejected, old files have to be archived by the operator.
+type AuditConfig struct {
+	RotateConfig
+	// Parameterized records the statements with placeholders instead of
+	// their parameters.
+	Parameterized bool
+}
+
+// AuditPlugin is a gorm.Plugin appending a record of every INSERT, UPDATE
+// and DELETE statement to a dedicated audit file, with the imcontext values
+// of the statement context. The records form a hash chain checked by
+// VerifyAudit.
+//
+// The statements are recorded when they run, also when their transaction is
+// rolled back later. When the db uses a TxPlugin registered before the
+// AuditPlugin, a COMMIT or ROLLBACK record with the txID of the statements
+// is added when their transaction ends, otherwise the outcome of a
+// transaction is not in the trail.
+type AuditPlugin struct {
+	cfg AuditConfig
+	w   *RotateWriter
+
+	mu   sync.Mutex
+	seq  uint64
+	last string
+	// txs holds the IDs of the transactions with records waiting for their
+	// outcome, nil without TxPlugin.
+	txs map[string]struct{}
+}
+
+var _ gorm.Plugin = (*AuditPlugin)(nil)
+
+// NewAuditPlugin opens the audit file and continues the chain of the records
+// it already holds, or of the newest rotated file when it is empty.
+func NewAuditPlugin(cfg AuditConfig) (*AuditPlugin, error) {
+	if cfg.MaxBackups > 0 || cfg.MaxAge > 0 || cfg.MaxTotalSize > 0 {
+		return nil, errors.New("logger: audit trail does not support retention limits")
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "logs"
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = "audit"
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	p := &AuditPlugin{cfg: cfg}
+	last, err := lastAuditRecord(cfg.RotateConfig)
+	if err != nil {
+		return nil, err
+	}
+	if last != nil {
+		p.seq, p.last = last.Seq, last.Hash
+	}
+	if p.w, err = NewRotateWriter(cfg.RotateConfig); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// lastAuditRecord returns the last record of the trail written as described
+// by cfg, from the active file or else from the newest rotated file holding a
+// record. It returns nil for a new trail.
+func lastAuditRecord(cfg RotateConfig) (*AuditRecord, error) {
+	rec, err := lastAuditRecordOf(filepath.Join(cfg.Dir, cfg.Prefix+".log"))
+	if rec != nil || err != nil {
+		return rec, err
+	}
+	backups, err := (&RotateWriter{cfg: cfg}).backups()
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range backups {
+		if rec, err := lastAuditRecordOf(b.name); rec != nil || err != nil {
+			return rec, err
+		}
+	}
+	return nil, nil
+}
+
+// lastAuditRecordOf returns the last record of the audit file name, which may
+// be compressed, nil when the file does not exist or is empty.
+func lastAuditRecordOf(name string) (*AuditRecord, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	switch filepath.Ext(name) {
+	case ".gz":
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("logger: last audit record of %s: %w", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	case ".zst":
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("logger: last audit record of %s: %w", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	var line []byte
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		if len(bytes.TrimSpace(sc.Bytes())) > 0 {
+			line = append(line[:0], sc.Bytes()...)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("logger: last audit record of %s: %w", name, err)
+	}
+	if line == nil {
+		return nil, nil
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return nil, fmt.Errorf("logger: last audit record of %s: %w", name, err)
+	}
+	return &rec, nil
+}
+
+func (p *AuditPlugin) Name() string {
+	return "logger:audit"
+}
+
+func (p *AuditPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registers := map[string]func(name string, fn func(*gorm.DB)) error{
+		"create": cb.Create().After("gorm:create").Register,
+		"update": cb.Update().After("gorm:update").Register,
+		"delete": cb.Delete().After("gorm:delete").Register,
+		"raw":    cb.Raw().After("gorm:raw").Register,
+	}
+	for operation, register := range registers {
+		if err := register("logger:audit_"+operation, p.after); err != nil {
+			return err
+		}
+	}
+	if pool, ok := db.ConnPool.(*txConnPool); ok {
+		p.mu.Lock()
+		if p.txs == nil {
+			p.txs = make(map[string]struct{})
+		}
+		p.mu.Unlock()
+		pool.plugin.onEnd(p.txEnd)
+	}
+	return nil
+}
+
+// txEnd records the outcome of a transaction in which statements were
+// recorded.
+func (p *AuditPlugin) txEnd(ctx context.Context, id, outcome string, err error) {
+	p.mu.Lock()
+	_, ok := p.txs[id]
+	delete(p.txs, id)
+	p.mu.Unlock()
+	if !ok {
+		return
+	}
+	rec := AuditRecord{
+		Operation:      strings.ToUpper(outcome),
+		TxID:           id,
+		OperationID:    imcontext.GetOperation(ctx),
+		OpUserID:       imcontext.GetOpUserID(ctx),
+		OpUserPlatform: imcontext.GetOpUserPlatform(ctx),
+		RemoteAddr:     imcontext.GetRemoteAddr(ctx),
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if err := p.Write(rec); err != nil {
+		if log := FromContext(ctx); log != nil {
+			log.Error(ctx, "write audit record failed", err, "txID", id)
+		}
+	}
+}
+
+var auditedOperations = map[string]bool{"INSERT": true, "REPLACE": true, "UPDATE": true, "DELETE": true}
+
+func (p *AuditPlugin) after(db *gorm.DB) {
+	if db.DryRun || db.Statement.SQL.Len() == 0 {
+		return
+	}
+	sql := db.Statement.SQL.String()
+	operation, table := parseSQL(sql)
+	if !auditedOperations[operation] {
+		return
+	}
+	if db.Statement.Table != "" {
+		table = db.Statement.Table
+	}
+	if !p.cfg.Parameterized {
+		sql = db.Dialector.Explain(sql, db.Statement.Vars...)
+	}
+	ctx := db.Statement.Context
+	rec := AuditRecord{
+		Operation:      operation,
+		Table:          table,
+		SQL:            sql,
+		Rows:           db.RowsAffected,
+		TxID:           TxID(ctx),
+		OperationID:    imcontext.GetOperation(ctx),
+		OpUserID:       imcontext.GetOpUserID(ctx),
+		OpUserPlatform: imcontext.GetOpUserPlatform(ctx),
+		RemoteAddr:     imcontext.GetRemoteAddr(ctx),
+	}
+	if db.Error != nil {
+		rec.Error = db.Error.Error()
+	}
+	if err := p.Write(rec); err != nil {
+		if log := FromContext(ctx); log != nil {
+			log.Error(ctx, "write audit record failed", err, "sql", sql)
+		}
+	}
+}
+
+// Write appends rec to the audit trail, filling its Seq, Time, PrevHash and
+// Hash.
+func (p *AuditPlugin) Write(rec AuditRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clock := p.cfg.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	rec.Seq = p.seq + 1
+	rec.Time = clock.Now().UTC().Round(0)
+	rec.PrevHash = p.last
+	var err error
+	if rec.Hash, err = rec.hash(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := p.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	p.seq, p.last = rec.Seq, rec.Hash
+	if p.txs != nil && rec.TxID != "" && auditedOperations[rec.Operation] {
+		p.txs[rec.TxID] = struct{}{}
+	}
+	return nil
+}
+
+// Close flushes and closes the audit file.
+func (p *AuditPlugin) Close() error {
+	return p.w.Close()
+}
+
+// VerifyAudit checks the chain of the records read from r, the first of
+// which must follow the record with the hash prevHash, "" for the start of
+// the trail. It returns the hash of the last record and the number of
+// records, so that the files of a rotated trail can be verified in order.
+func VerifyAudit(r io.Reader, prevHash string) (string, int, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	var (
+		n   int
+		seq uint64
+	)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return prevHash, n, fmt.Errorf("%w: line %d: %v", ErrAuditTampered, line, err)
+		}
+		if rec.PrevHash != prevHash {
+			return prevHash, n, fmt.Errorf("%w: line %d: record %d does not follow the previous record",
+				ErrAuditTampered, line, rec.Seq)
+		}
+		if n > 0 && rec.Seq != seq+1 {
+			return prevHash, n, fmt.Errorf("%w: line %d: record %d follows record %d",
+				ErrAuditTampered, line, rec.Seq, seq)
+		}
+		hash, err := rec.hash()
+		if err != nil {
+			return prevHash, n, err
+		}
+		if hash != rec.Hash {
+			return prevHash, n, fmt.Errorf("%w: line %d: record %d does not match its hash",
+				ErrAuditTampered, line, rec.Seq)
+		}
+		prevHash, seq = rec.Hash, rec.Seq
+		n++
+	}
+	return prevHash, n, sc.Err()
+}
@@ -0,0 +1,218 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/yunbaifan/pkg/imcontext"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func TestAuditPlugin(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := NewAuditPlugin(AuditConfig{RotateConfig: RotateConfig{Dir: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&optionUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(audit); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := imcontext.WithOpUserID(context.Background(), "u1")
+	ctx = imcontext.WithOpUserPlatform(ctx, "ios")
+	ctx = imcontext.WithRemoteAddr(ctx, "10.0.0.1")
+	ctx = imcontext.WithOperation(ctx, "op-9")
+	tx := db.WithContext(ctx)
+	u := optionUser{Name: "alice"}
+	tx.Create(&u)
+	tx.Find(&[]optionUser{})
+	tx.Model(&u).Update("name", "bob")
+	tx.Exec("DELETE FROM option_users WHERE id = ?", u.ID)
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	name := filepath.Join(dir, "audit.log")
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last, n, err := VerifyAudit(bytes.NewReader(data), "")
+	if err != nil || n != 3 {
+		t.Fatalf("VerifyAudit = %d records, %v\n%s", n, err, data)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i, op := range []string{"INSERT", "UPDATE", "DELETE"} {
+		if !strings.Contains(lines[i], `"operation":"`+op+`"`) || !strings.Contains(lines[i], `"opUserID":"u1"`) ||
+			!strings.Contains(lines[i], `"remoteAddr":"10.0.0.1"`) || !strings.Contains(lines[i], `"operationID":"op-9"`) {
+			t.Errorf("record %d = %s", i, lines[i])
+		}
+	}
+	if !strings.Contains(lines[1], "bob") {
+		t.Errorf("update record without its parameters: %s", lines[1])
+	}
+
+	// the chain continues after reopening the trail
+	audit, err = NewAuditPlugin(AuditConfig{RotateConfig: RotateConfig{Dir: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := audit.Write(AuditRecord{Operation: "DELETE", Table: "option_users"}); err != nil {
+		t.Fatal(err)
+	}
+	audit.Close()
+	data, _ = os.ReadFile(name)
+	if _, n, err := VerifyAudit(bytes.NewReader(data), ""); err != nil || n != 4 {
+		t.Fatalf("VerifyAudit after reopen = %d records, %v", n, err)
+	}
+	if _, _, err := VerifyAudit(strings.NewReader(strings.SplitAfter(string(data), "\n")[3]), last); err != nil {
+		t.Errorf("VerifyAudit from the last hash = %v", err)
+	}
+
+	tampered := strings.Replace(string(data), "bob", "eve", 1)
+	if _, _, err := VerifyAudit(strings.NewReader(tampered), ""); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("modified record: %v", err)
+	}
+	lines = strings.SplitAfter(string(data), "\n")
+	removed := lines[0] + lines[2] + lines[3]
+	if _, _, err := VerifyAudit(strings.NewReader(removed), ""); !errors.Is(err, ErrAuditTampered) {
+		t.Errorf("removed record: %v", err)
+	}
+}
+
+func TestAuditPluginTxOutcome(t *testing.T) {
+	dir := t.TempDir()
+	audit, err := NewAuditPlugin(AuditConfig{RotateConfig: RotateConfig{Dir: dir}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&optionUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(NewTxPlugin(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(audit); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := imcontext.WithOpUserID(context.Background(), "u1")
+	errAbort := errors.New("abort")
+	_ = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&optionUser{Name: "a"}).Error
+	})
+	_ = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&optionUser{Name: "b"}).Error; err != nil {
+			return err
+		}
+		return errAbort
+	})
+	// transactions without audited statements are not recorded
+	_ = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Find(&[]optionUser{}).Error
+	})
+	if err := audit.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, n, err := VerifyAudit(bytes.NewReader(data), ""); err != nil || n != 4 {
+		t.Fatalf("VerifyAudit = %d records, %v\n%s", n, err, data)
+	}
+	var recs []AuditRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	for i, op := range []string{"INSERT", "COMMIT", "INSERT", "ROLLBACK"} {
+		if recs[i].Operation != op || recs[i].TxID == "" || recs[i].OpUserID != "u1" {
+			t.Errorf("record %d = %+v, want %s", i, recs[i], op)
+		}
+	}
+	if recs[0].TxID != recs[1].TxID || recs[2].TxID != recs[3].TxID || recs[0].TxID == recs[2].TxID {
+		t.Errorf("tx IDs = %s %s %s %s", recs[0].TxID, recs[1].TxID, recs[2].TxID, recs[3].TxID)
+	}
+}
+
+func TestAuditPluginContinuesRotatedTrail(t *testing.T) {
+	for _, compress := range []string{CompressNone, CompressGzip, CompressZstd} {
+		t.Run("compress="+compress, func(t *testing.T) {
+			dir := t.TempDir()
+			cfg := AuditConfig{RotateConfig: RotateConfig{Dir: dir, Prefix: "audit", MaxSize: 1, Compress: compress}}
+			audit, err := NewAuditPlugin(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 3; i++ {
+				if err := audit.Write(AuditRecord{Operation: "DELETE", Table: "option_users"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			last := audit.last
+			audit.Close()
+			// rotate the active file out, leaving an empty one
+			w := &RotateWriter{cfg: cfg.RotateConfig}
+			backup := w.backupName(time.Now().Add(time.Hour))
+			if err := os.Rename(filepath.Join(dir, "audit.log"), backup); err != nil {
+				t.Fatal(err)
+			}
+			if compress != CompressNone {
+				if _, _, err := compressFile(backup, compress); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(dir, "audit.log"), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			audit, err = NewAuditPlugin(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer audit.Close()
+			if audit.seq != 3 || audit.last != last {
+				t.Errorf("chain continues at seq %d, hash %s, want 3, %s", audit.seq, audit.last, last)
+			}
+		})
+	}
+}
+
+func TestAuditPluginRejectsRetention(t *testing.T) {
+	for _, cfg := range []RotateConfig{{MaxBackups: 1}, {MaxAge: time.Hour}, {MaxTotalSize: 1}} {
+		cfg.Dir = t.TempDir()
+		if _, err := NewAuditPlugin(AuditConfig{RotateConfig: cfg}); err == nil {
+			t.Errorf("NewAuditPlugin(%+v) succeeded", cfg)
+		}
+	}
+}
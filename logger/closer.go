@@ -0,0 +1,55 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// closer collects the resources opened for a logger so that they are
+// released exactly once, whichever derived logger calls Close.
+type closer struct {
+	mu      sync.Mutex
+	closers []io.Closer
+	closed  bool
+	err     error
+}
+
+func (c *closer) add(cl io.Closer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closers = append(c.closers, cl)
+}
+
+// replace moves the resources of next into c and returns a closer holding
+// the resources c had before.
+func (c *closer) replace(next *closer) *closer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	next.mu.Lock()
+	defer next.mu.Unlock()
+	old := &closer{closers: c.closers}
+	c.closers, next.closers = next.closers, nil
+	return old
+}
+
+func (c *closer) close(errs ...error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return c.err
+	}
+	c.closed = true
+	for i := len(c.closers) - 1; i >= 0; i-- {
+		errs = append(errs, c.closers[i].Close())
+	}
+	c.closers = nil
+	c.err = errors.Join(errs...)
+	return c.err
+}
+
+// stdWriter hides Sync of os.Stdout/os.Stderr, which fails on terminals and
+// pipes and would make every Sync/Close report an error.
+type stdWriter struct {
+	io.Writer
+}
@@ -0,0 +1,525 @@
+package logger
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// Level is the LogLevel of LoggerConifg. It is a number from 0 (panic) to 6
+// (debug) and can also be written as a level name in config files and
+// environment variables.
+type Level int
+
+const (
+	LevelPanic Level = iota
+	LevelFatal
+	LevelError
+	LevelWarn
+	LevelInfo
+	LevelDebug
+)
+
+var levelNames = map[string]Level{
+	"panic":   LevelPanic,
+	"fatal":   LevelFatal,
+	"error":   LevelError,
+	"warn":    LevelWarn,
+	"warning": LevelWarn,
+	"info":    LevelInfo,
+	"debug":   LevelDebug,
+}
+
+func (l Level) String() string {
+	return getLevel(int(l)).String()
+}
+
+func (l *Level) UnmarshalText(text []byte) error {
+	s := strings.ToLower(strings.TrimSpace(string(text)))
+	if lvl, ok := levelNames[s]; ok {
+		*l = lvl
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("unknown log level %q", string(text))
+	}
+	*l = Level(n)
+	return nil
+}
+
+func (l *Level) UnmarshalJSON(data []byte) error {
+	if s, err := strconv.Unquote(string(data)); err == nil {
+		return l.UnmarshalText([]byte(s))
+	}
+	return l.UnmarshalText(data)
+}
+
+func (l *Level) UnmarshalYAML(node *yaml.Node) error {
+	return l.UnmarshalText([]byte(node.Value))
+}
+
+// duration decodes a time.Duration field from JSON the way setValue parses
+// it, from a number kept as is or from a Go duration string such as "1s".
+// The configs with durations decode them through it in UnmarshalJSON.
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		s = string(data)
+	}
+	var v time.Duration
+	if err := setValue(reflect.ValueOf(&v).Elem(), s); err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// ApplyDefaults sets the zero valued fields of cfg, and of the non-nil
+// nested configs, to the value of their default tag. It cannot tell a zero
+// set on purpose from an unset field, LoadConfig applies the defaults before
+// reading the file and keeps the zero values it sets.
+func ApplyDefaults(cfg *LoggerConifg) error {
+	return applyDefaults(reflect.ValueOf(cfg).Elem(), "")
+}
+
+func applyDefaults(v reflect.Value, path string) error {
+	t := v.Type()
+	var errs []error
+	for i := 0; i < t.NumField(); i++ {
+		sf, fv := t.Field(i), v.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := joinPath(path, fieldName(sf))
+		switch {
+		case fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct:
+			if !fv.IsNil() {
+				errs = append(errs, applyDefaults(fv.Elem(), name))
+			}
+			continue
+		case fv.Kind() == reflect.Struct && sf.Anonymous:
+			errs = append(errs, applyDefaults(fv, path))
+			continue
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < fv.Len(); j++ {
+				errs = append(errs, applyDefaults(fv.Index(j), fmt.Sprintf("%s[%d]", name, j)))
+			}
+			continue
+		}
+		def, ok := sf.Tag.Lookup("default")
+		if !ok || def == "" || !fv.IsZero() {
+			continue
+		}
+		if err := setValue(fv, def); err != nil {
+			errs = append(errs, fmt.Errorf("%s: default %q: %w", name, def, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func fieldName(sf reflect.StructField) string {
+	if tag := strings.Split(sf.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+		return tag
+	}
+	return sf.Name
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// setValue parses s into v. Durations accept Go duration strings or a number
+// of nanoseconds, as in JSON and YAML, the fields counting hours are plain
+// integers.
+func setValue(v reflect.Value, s string) error {
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			v.SetInt(n)
+			return nil
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		parts := strings.Split(s, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		v.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+type loadOptions struct {
+	envPrefix string
+	interval  time.Duration
+	onError   func(error)
+}
+
+type LoadOption func(o *loadOptions)
+
+// WithEnvPrefix sets the prefix of the environment variables overriding the
+// config, "LOG" by default. A field is overridden by PREFIX_<JSON NAME> in
+// upper case, nested fields join their names with "_", e.g. LOG_LOGLEVEL or
+// LOG_ASYNC_BUFFERSIZE. An empty prefix disables environment overrides.
+func WithEnvPrefix(prefix string) LoadOption {
+	return func(o *loadOptions) {
+		o.envPrefix = prefix
+	}
+}
+
+// WithReloadInterval sets how often WatchConfig checks the config file.
+func WithReloadInterval(interval time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.interval = interval
+	}
+}
+
+// WithReloadErrorHandler sets the function WatchConfig reports reload
+// failures to, by default they are logged through the watched logger.
+func WithReloadErrorHandler(fn func(error)) LoadOption {
+	return func(o *loadOptions) {
+		o.onError = fn
+	}
+}
+
+func newLoadOptions(opts []LoadOption) *loadOptions {
+	o := &loadOptions{envPrefix: "LOG", interval: 5 * time.Second}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// LoadConfig reads a YAML or JSON config file, chosen by its extension, on
+// top of the default tags, applies the environment overrides and validates
+// the result. An empty path only uses defaults and environment variables.
+func LoadConfig(path string, opts ...LoadOption) (*LoggerConifg, error) {
+	return loadConfig(path, newLoadOptions(opts))
+}
+
+func loadConfig(path string, o *loadOptions) (*LoggerConifg, error) {
+	cfg := &LoggerConifg{}
+	if err := ApplyDefaults(cfg); err != nil {
+		return nil, err
+	}
+	// the nested configs get their defaults before the file and the
+	// environment are applied, so that the zero values these set are kept,
+	// and are dropped again when neither of them enabled the config
+	v := reflect.ValueOf(cfg).Elem()
+	var nested []int
+	for i := 0; i < v.NumField(); i++ {
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct && fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+			if err := applyDefaults(fv.Elem(), fieldName(v.Type().Field(i))); err != nil {
+				return nil, err
+			}
+			nested = append(nested, i)
+		}
+	}
+	var keys map[string]json.RawMessage
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".yaml", ".yml":
+			// decoded through JSON, so that durations are read the same way
+			// in both formats
+			var m map[string]any
+			if err = yaml.Unmarshal(data, &m); err == nil {
+				data, err = json.Marshal(m)
+			}
+		case ".json":
+		default:
+			err = fmt.Errorf("unsupported config file extension %q", ext)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, cfg)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &keys)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("logger: parse %s: %w", path, err)
+		}
+	}
+	if o.envPrefix != "" {
+		if err := applyEnv(v, o.envPrefix, ""); err != nil {
+			return nil, err
+		}
+	}
+	for _, i := range nested {
+		name := fieldName(v.Type().Field(i))
+		if !hasKey(keys, name) && (o.envPrefix == "" || !hasEnvPrefix(o.envPrefix+"_"+strings.ToUpper(name)+"_")) {
+			v.Field(i).Set(reflect.Zero(v.Field(i).Type()))
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// hasKey reports whether keys holds name, ignoring case like encoding/json.
+func hasKey(keys map[string]json.RawMessage, name string) bool {
+	for k := range keys {
+		if strings.EqualFold(k, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func applyEnv(v reflect.Value, prefix, path string) error {
+	t := v.Type()
+	var errs []error
+	for i := 0; i < t.NumField(); i++ {
+		sf, fv := t.Field(i), v.Field(i)
+		if !sf.IsExported() || sf.Tag.Get("json") == "-" {
+			continue
+		}
+		if fv.Kind() == reflect.Struct && sf.Anonymous {
+			errs = append(errs, applyEnv(fv, prefix, path))
+			continue
+		}
+		name := joinPath(path, fieldName(sf))
+		key := prefix + "_" + strings.ToUpper(fieldName(sf))
+		if fv.Kind() == reflect.Pointer && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				if !hasEnvPrefix(key + "_") {
+					continue
+				}
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			errs = append(errs, applyEnv(fv.Elem(), key, name))
+			continue
+		}
+		s, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: environment %s=%q: %w", name, key, s, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func hasEnvPrefix(prefix string) bool {
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxHours is the largest number of hours a time.Duration holds.
+const maxHours = int(math.MaxInt64 / int64(time.Hour))
+
+// Validate checks cfg and reports every invalid field.
+func (cfg *LoggerConifg) Validate() error {
+	var errs []error
+	check := func(ok bool, field, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+		}
+	}
+	checkLevel := func(field, s string) {
+		if s == "" {
+			return
+		}
+		var lvl zapcore.Level
+		check(lvl.UnmarshalText([]byte(s)) == nil, field, "unknown level %q", s)
+	}
+	check(cfg.LogLevel >= LevelPanic && cfg.LogLevel <= 6, "logLevel",
+		"must be a level name or a number between 0 and 6, got %d", cfg.LogLevel)
+	check(cfg.RotationTime >= 0 && cfg.RotationTime <= maxHours, "rotationTime",
+		"must be a number of hours between 0 and %d, got %d", maxHours, cfg.RotationTime)
+	check(cfg.MaxSize >= 0, "maxSize", "must not be negative")
+	check(cfg.MaxAge >= 0 && cfg.MaxAge <= maxHours, "maxAge",
+		"must be a number of hours between 0 and %d, got %d", maxHours, cfg.MaxAge)
+	check(cfg.MaxTotalSize >= 0, "maxTotalSize", "must not be negative")
+	check(cfg.Compress == CompressNone || cfg.Compress == CompressGzip || cfg.Compress == CompressZstd,
+		"compress", "must be gzip or zstd, got %q", cfg.Compress)
+	for i, sink := range cfg.sinks() {
+		field := fmt.Sprintf("sinks[%d]", i)
+		switch sink.Type {
+		case SinkFile:
+			check(sink.Path != "" || cfg.Location != "", field+".path", "file sink needs a path or logLocation")
+			check(sink.Prefix != "" || cfg.LogPrefixName != "", field+".prefix", "file sink needs a prefix or logPrefixName")
+		case SinkTCP, SinkUDP:
+			check(sink.Address != "", field+".address", "%s sink needs an address", sink.Type)
+		case SinkWriter:
+			check(sink.Writer != nil, field+".writer", "writer sink needs a Writer")
+		case SinkStdout, SinkStderr, SinkSyslog:
+		default:
+			check(false, field+".type", "unknown sink type %q", sink.Type)
+		}
+		switch sink.Encoding {
+		case "", EncodingJSON, EncodingConsole, EncodingLogfmt:
+		default:
+			check(false, field+".encoding", "must be json, console or logfmt, got %q", sink.Encoding)
+		}
+		checkLevel(field+".level", sink.Level)
+	}
+	if cfg.Async != nil {
+		switch cfg.Async.Overflow {
+		case "", OverflowBlock, OverflowDropOldest, OverflowDrop:
+		default:
+			check(false, "async.overflow", "must be block, drop_oldest or drop, got %q", cfg.Async.Overflow)
+		}
+		check(cfg.Async.BufferSize >= 0, "async.bufferSize", "must not be negative")
+	}
+	if cfg.Sampling != nil {
+		for name := range cfg.Sampling.Levels {
+			checkLevel("sampling.levels", name)
+		}
+	}
+	if cfg.RateLimit != nil {
+		checkLevel("rateLimit.level", cfg.RateLimit.Level)
+		check(cfg.RateLimit.Rate >= 0, "rateLimit.rate", "must not be negative")
+	}
+	if cfg.Errors != nil {
+		_, err := newErrorEncoding(cfg.Errors)
+		check(err == nil, "errors", "%v", err)
+	}
+	if cfg.Redact != nil {
+		_, err := newRedactor(cfg.Redact)
+		check(err == nil, "redact", "%v", err)
+	}
+	return errors.Join(errs...)
+}
+
+// WatchConfig checks the config file at path periodically until ctx is done
+// and reconfigures l with the reloaded config whenever the file changes. A
+// logger that cannot be rebuilt in place only gets its level updated.
+//
+// A file that fails to load is tried again on the next check, since it may
+// still be being written, and the error is only reported when the file has
+// not changed in between.
+func WatchConfig(ctx context.Context, path string, l Log, opts ...LoadOption) error {
+	o := newLoadOptions(opts)
+	if o.onError == nil {
+		o.onError = func(err error) {
+			l.Error(ctx, "reload logger config failed", err, "path", path)
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	last := newFileVersion(info)
+	var failed fileVersion
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			o.onError(err)
+			continue
+		}
+		cur := newFileVersion(info)
+		if cur == last {
+			continue
+		}
+		if err := reload(path, l, o); err != nil {
+			if cur == failed {
+				o.onError(err)
+				last = cur
+			}
+			failed = cur
+			continue
+		}
+		last = cur
+	}
+}
+
+// fileVersion identifies the content of a config file by its modification
+// time and size, a rewrite within the mtime granularity still changes the
+// size in practice.
+type fileVersion struct {
+	modTime int64
+	size    int64
+}
+
+func newFileVersion(info os.FileInfo) fileVersion {
+	return fileVersion{modTime: info.ModTime().UnixNano(), size: info.Size()}
+}
+
+func reload(path string, l Log, o *loadOptions) error {
+	cfg, err := loadConfig(path, o)
+	if err != nil {
+		return err
+	}
+	if r, ok := l.(interface{ Reconfigure(*LoggerConifg) error }); ok {
+		return r.Reconfigure(cfg)
+	}
+	l.SetLevel(getLevel(int(cfg.LogLevel)))
+	return nil
+}
@@ -0,0 +1,273 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	err := os.WriteFile(path, []byte(`
+logPrefixName: im
+logLevel: warn
+isStdout: false
+async:
+  overflow: drop
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_LOG_MODULENAME", "msg-gateway")
+	t.Setenv("TEST_LOG_ASYNC_BUFFERSIZE", "64")
+
+	cfg, err := LoadConfig(path, WithEnvPrefix("TEST_LOG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != LevelWarn {
+		t.Errorf("logLevel = %v, want warn", cfg.LogLevel)
+	}
+	if cfg.IsStdout || !cfg.IsJson {
+		t.Errorf("isStdout = %v, isJson = %v, want false and the default true", cfg.IsStdout, cfg.IsJson)
+	}
+	if cfg.LogPrefixName != "im" || cfg.ModuleName != "msg-gateway" || cfg.Location != "logs" {
+		t.Errorf("unexpected names: %+v", cfg)
+	}
+	if cfg.Async.BufferSize != 64 || cfg.Async.FlushInterval != time.Second || cfg.Async.Overflow != OverflowDrop {
+		t.Errorf("async = %+v", *cfg.Async)
+	}
+}
+
+func TestLoadConfigDurations(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"log.json": `{"rotationTime": 12, "async": {"flushInterval": "2s"}, "sampling": {"tick": "500ms"},
+			"rateLimit": {"summaryInterval": 30000000000}}`,
+		"log.yaml": `
+rotationTime: 12
+async:
+  flushInterval: 2s
+sampling:
+  tick: 500ms
+rateLimit:
+  summaryInterval: 30000000000
+`,
+	}
+	for name, body := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(path, WithEnvPrefix(""))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.RotationTime != 12 {
+				t.Errorf("rotationTime = %d, want 12 hours", cfg.RotationTime)
+			}
+			if cfg.Async.FlushInterval != 2*time.Second {
+				t.Errorf("async.flushInterval = %v, want 2s", cfg.Async.FlushInterval)
+			}
+			if cfg.Sampling.Tick != 500*time.Millisecond {
+				t.Errorf("sampling.tick = %v, want 500ms", cfg.Sampling.Tick)
+			}
+			if cfg.RateLimit.SummaryInterval != 30*time.Second {
+				t.Errorf("rateLimit.summaryInterval = %v, want 30s", cfg.RateLimit.SummaryInterval)
+			}
+		})
+	}
+}
+
+func TestLoadConfigHours(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"duration.yaml": "maxAge: 168h\n",
+		"overflow.yaml": "rotationTime: 3000000\n",
+		"negative.json": `{"maxAge": -1}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path, WithEnvPrefix("")); err == nil {
+			t.Errorf("%s: LoadConfig succeeded", name)
+		}
+	}
+
+	t.Setenv("TEST_LOG_ROTATIONTIME", "24h")
+	if _, err := LoadConfig("", WithEnvPrefix("TEST_LOG")); err == nil {
+		t.Error("rotationTime=24h from the environment accepted")
+	}
+	t.Setenv("TEST_LOG_ROTATIONTIME", "6")
+	t.Setenv("TEST_LOG_MAXAGE", "168")
+	cfg, err := LoadConfig("", WithEnvPrefix("TEST_LOG"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RotationTime != 6 || cfg.MaxAge != 168 {
+		t.Errorf("rotationTime = %d, maxAge = %d, want 6 and 168 hours", cfg.RotationTime, cfg.MaxAge)
+	}
+}
+
+func TestLoadConfigKeepsExplicitZeros(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	err := os.WriteFile(path, []byte(`
+rateLimit:
+  rate: 0
+  burst: 5
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path, WithEnvPrefix(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RateLimit.Rate != 0 || cfg.RateLimit.Burst != 5 || cfg.RateLimit.Level != "warn" {
+		t.Errorf("rateLimit = %+v, want the explicit rate 0 and the default level", *cfg.RateLimit)
+	}
+	if cfg.Async != nil || cfg.Sampling != nil {
+		t.Errorf("async = %v, sampling = %v, want the configs left out of the file disabled", cfg.Async, cfg.Sampling)
+	}
+}
+
+func TestLoadConfigValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	err := os.WriteFile(path, []byte(`{"logLevel": 9, "compress": "rar", "sinks": [{"type": "tcp"}]}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfig(path, WithEnvPrefix(""))
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+	for _, want := range []string{"logLevel:", "compress:", "sinks[0].address:"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
+
+func TestWatchConfigReconfigures(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.json")
+	write := func(body string, mtime time.Time) {
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	write(`{"logLevel": "info", "isStdout": false, "logLocation": "`+dir+`", "logPrefixName": "a"}`, now.Add(-time.Minute))
+	cfg, err := LoadConfig(path, WithEnvPrefix(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	derived := l.WithValues("k", "v")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchConfig(ctx, path, l, WithEnvPrefix(""), WithReloadInterval(10*time.Millisecond),
+			WithReloadErrorHandler(func(err error) { t.Error(err) }))
+	}()
+	// keep touching the file, the watcher may take its first stat after any
+	// single write
+	for i := 0; l.GetLevel() != zapcore.DebugLevel; i++ {
+		if i == 500 {
+			t.Fatal("config was not reloaded")
+		}
+		write(`{"logLevel": "debug", "isStdout": false, "logLocation": "`+dir+`", "logPrefixName": "b"}`,
+			now.Add(time.Duration(i)*time.Second))
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	derived.Debug(context.Background(), "after reload")
+	if err := l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "b.*.log"))
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want one file with the new prefix", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "after reload") || !strings.Contains(string(data), `"k":"v"`) {
+		t.Errorf("new file = %s", data)
+	}
+}
+
+func TestWatchConfigRetriesPartialWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "log.json")
+	mtime := time.Now().Add(-time.Minute)
+	write := func(body string) {
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{"logLevel": "info", "isStdout": false, "logLocation": "` + dir + `"}`)
+	cfg, err := LoadConfig(path, WithEnvPrefix(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	var (
+		mu       sync.Mutex
+		reported []error
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchConfig(ctx, path, l, WithEnvPrefix(""), WithReloadInterval(10*time.Millisecond),
+			WithReloadErrorHandler(func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				reported = append(reported, err)
+			}))
+	}()
+	// let the watcher take its first stat, then write a half written file
+	// followed by the complete one, both with the same mtime
+	time.Sleep(50 * time.Millisecond)
+	mtime = mtime.Add(time.Second)
+	write(`{"logLevel": "debug", "isSt`)
+	time.Sleep(100 * time.Millisecond)
+	write(`{"logLevel": "debug", "isStdout": false, "logLocation": "` + dir + `"}`)
+	for i := 0; l.GetLevel() != zapcore.DebugLevel; i++ {
+		if i == 500 {
+			t.Fatal("config was not reloaded after the write completed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 {
+		t.Errorf("reported %d errors, want 1 for the half written file: %v", len(reported), reported)
+	}
+}
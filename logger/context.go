@@ -0,0 +1,22 @@
+package logger
+
+import "context"
+
+type loggerKey struct{}
+
+// IntoContext returns a copy of ctx carrying l, so that the package-level
+// functions called with the derived context log through l.
+func IntoContext(ctx context.Context, l Log) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the Log stored in ctx by IntoContext, or the global
+// Logger when ctx carries none.
+func FromContext(ctx context.Context) Log {
+	if ctx != nil {
+		if l, ok := ctx.Value(loggerKey{}).(Log); ok && l != nil {
+			return l
+		}
+	}
+	return Logger
+}
@@ -0,0 +1,155 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/yunbaifan/pkg/utils/errs"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const levelOff = "off"
+
+// ErrorConfig controls how the err argument of Warn/Error is logged. The
+// message is always logged as "error", the key/value pairs of errs.Fields as
+// the "errorFields" object, the cause chain as "errorChain" from
+// ChainLevel on and the stack recorded by pkg/errors as "errorStack" from
+// StackLevel on. A level of "off" disables the field.
+type ErrorConfig struct {
+	ChainLevel    string `json:"chainLevel" yaml:"chainLevel" default:"warn" description:"输出错误链的最低日志级别, off 表示不输出"`
+	StackLevel    string `json:"stackLevel" yaml:"stackLevel" default:"error" description:"输出错误堆栈的最低日志级别, off 表示不输出"`
+	MaxStackDepth int    `json:"maxStackDepth" yaml:"maxStackDepth" default:"32" description:"错误堆栈的最大层数"`
+}
+
+type errorEncoding struct {
+	chainLevel zapcore.Level
+	stackLevel zapcore.Level
+	maxDepth   int
+}
+
+func parseErrorLevel(s string, def zapcore.Level) (zapcore.Level, error) {
+	switch s {
+	case "":
+		return def, nil
+	case levelOff:
+		return zapcore.InvalidLevel, nil
+	}
+	var lvl zapcore.Level
+	err := lvl.UnmarshalText([]byte(s))
+	return lvl, err
+}
+
+func newErrorEncoding(cfg *ErrorConfig) (errorEncoding, error) {
+	enc := errorEncoding{chainLevel: zapcore.WarnLevel, stackLevel: zapcore.ErrorLevel, maxDepth: 32}
+	if cfg == nil {
+		return enc, nil
+	}
+	var err error
+	if enc.chainLevel, err = parseErrorLevel(cfg.ChainLevel, enc.chainLevel); err != nil {
+		return enc, fmt.Errorf("logger: errors.chainLevel: %w", err)
+	}
+	if enc.stackLevel, err = parseErrorLevel(cfg.StackLevel, enc.stackLevel); err != nil {
+		return enc, fmt.Errorf("logger: errors.stackLevel: %w", err)
+	}
+	if cfg.MaxStackDepth > 0 {
+		enc.maxDepth = cfg.MaxStackDepth
+	}
+	return enc, nil
+}
+
+func (e errorEncoding) enabled(min, lvl zapcore.Level) bool {
+	return min != zapcore.InvalidLevel && lvl >= min
+}
+
+type stackTracer interface {
+	StackTrace() pkgerrors.StackTrace
+}
+
+// StackFrame is one frame of the "errorStack" field.
+type StackFrame struct {
+	Func string `json:"func"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// fields returns the key/value pairs describing err when logged at lvl.
+func (e errorEncoding) fields(lvl zapcore.Level, err error) []any {
+	kv := []any{"error", err.Error()}
+	if f := newErrorFields(errs.Fields(err)); len(f) > 0 {
+		kv = append(kv, "errorFields", f)
+	}
+	var (
+		chain []string
+		stack pkgerrors.StackTrace
+	)
+	for cur := err; cur != nil; cur = errs.Next(cur) {
+		if msg := cur.Error(); len(chain) == 0 || chain[len(chain)-1] != msg {
+			chain = append(chain, msg)
+		}
+		// the innermost stack is the closest to where the error happened
+		if st, ok := cur.(stackTracer); ok {
+			stack = st.StackTrace()
+		}
+	}
+	if e.enabled(e.chainLevel, lvl) && len(chain) > 1 {
+		kv = append(kv, "errorChain", chain)
+	}
+	if e.enabled(e.stackLevel, lvl) && len(stack) > 0 {
+		kv = append(kv, "errorStack", e.frames(stack))
+	}
+	return kv
+}
+
+func (e errorEncoding) frames(stack pkgerrors.StackTrace) []StackFrame {
+	if len(stack) > e.maxDepth {
+		stack = stack[:e.maxDepth]
+	}
+	frames := make([]StackFrame, 0, len(stack))
+	for _, f := range stack {
+		pc := uintptr(f) - 1
+		fn := runtime.FuncForPC(pc)
+		if fn == nil {
+			continue
+		}
+		file, line := fn.FileLine(pc)
+		frames = append(frames, StackFrame{Func: fn.Name(), File: file, Line: line})
+	}
+	return frames
+}
+
+// errorFields are the key/value pairs of errs.Fields, logged as one object so
+// that they cannot replace the other fields of the entry.
+type errorFields []any
+
+// newErrorFields returns the pairs of kv without the repeated keys, the value
+// of the outermost error is kept.
+func newErrorFields(kv []any) errorFields {
+	seen := make(map[string]bool, len(kv)/2)
+	var f errorFields
+	for i := 0; i+1 < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		if !seen[key] {
+			seen[key] = true
+			f = append(f, key, kv[i+1])
+		}
+	}
+	return f
+}
+
+func (f errorFields) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i := 0; i < len(f); i += 2 {
+		zap.Any(f[i].(string), f[i+1]).AddTo(enc)
+	}
+	return nil
+}
+
+func (f errorFields) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(f)/2)
+	for i := 0; i < len(f); i += 2 {
+		attrs = append(attrs, slog.Any(f[i].(string), f[i+1]))
+	}
+	return slog.GroupValue(attrs...)
+}
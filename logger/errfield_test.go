@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/yunbaifan/pkg/utils/errs"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestErrorFields(t *testing.T) {
+	l, logs := newObservedLogger(zapcore.DebugLevel)
+	root := errors.New("connection refused")
+	err := errs.Warp(errs.Warp(root, "query", "table", "users", "uid", 0), "load user", "uid", 7, "error", "shadowed")
+
+	l.Error(context.Background(), "failed", err)
+	l.Warn(context.Background(), "degraded", err)
+
+	entries := logs.All()
+	fields := entries[0].ContextMap()
+	if fields["error"] != err.Error() {
+		t.Errorf("error = %v", fields["error"])
+	}
+	errFields, _ := fields["errorFields"].(map[string]interface{})
+	if errFields["uid"] != int64(7) || errFields["table"] != "users" || errFields["error"] != "shadowed" {
+		t.Errorf("errorFields = %v", fields["errorFields"])
+	}
+	if _, ok := fields["uid"]; ok {
+		t.Error("error fields logged at the top level")
+	}
+	chain, _ := fields["errorChain"].([]interface{})
+	if len(chain) != 3 || chain[len(chain)-1] != "connection refused" {
+		t.Errorf("errorChain = %#v", fields["errorChain"])
+	}
+	stack, _ := fields["errorStack"].([]StackFrame)
+	found := false
+	for _, f := range stack {
+		found = found || strings.HasSuffix(f.Func, "TestErrorFields")
+	}
+	if !found {
+		t.Errorf("errorStack = %#v", fields["errorStack"])
+	}
+
+	fields = entries[1].ContextMap()
+	if _, ok := fields["errorStack"]; ok {
+		t.Error("errorStack logged below the stack level")
+	}
+	if _, ok := fields["errorChain"]; !ok {
+		t.Error("errorChain missing at warn level")
+	}
+}
@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"os"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	exitMu   sync.Mutex
+	exitFunc = os.Exit
+)
+
+// SetExitFunc replaces the function Fatal calls after flushing the logger,
+// os.Exit by default, and returns a function restoring the previous one.
+// Tests use it to observe Fatal without terminating the process.
+func SetExitFunc(fn func(code int)) (restore func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	prev := exitFunc
+	exitFunc = fn
+	return func() {
+		exitMu.Lock()
+		defer exitMu.Unlock()
+		exitFunc = prev
+	}
+}
+
+func exit(code int) {
+	exitMu.Lock()
+	fn := exitFunc
+	exitMu.Unlock()
+	fn(code)
+}
+
+// exitHook runs after a fatal entry has been written, it flushes and closes
+// the outputs so that buffered entries are not lost on exit.
+type exitHook struct {
+	z *zapLogger
+}
+
+func (h exitHook) OnWrite(*zapcore.CheckedEntry, []zapcore.Field) {
+	_ = h.z.Close()
+	exit(1)
+}
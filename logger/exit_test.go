@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestFatalFlushesAndExits(t *testing.T) {
+	var code int
+	restore := SetExitFunc(func(c int) { code = c })
+	defer restore()
+
+	var buf bytes.Buffer
+	l, err := New(&LoggerConifg{
+		LogLevel: LevelInfo,
+		Async:    &AsyncConfig{},
+		Sinks:    []SinkConfig{{Type: SinkWriter, Writer: &buf, Encoding: EncodingJSON}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Fatal(context.Background(), "cannot start", nil)
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	if !strings.Contains(buf.String(), `"level":"FATAL"`) {
+		t.Errorf("fatal entry not flushed before exit: %q", buf.String())
+	}
+}
+
+func TestDPanicInDevelopment(t *testing.T) {
+	for _, dev := range []bool{false, true} {
+		var buf bytes.Buffer
+		l, err := New(&LoggerConifg{
+			LogLevel:    LevelInfo,
+			Development: dev,
+			Sinks:       []SinkConfig{{Type: SinkWriter, Writer: &buf}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		panicked := func() (p bool) {
+			defer func() { p = recover() != nil }()
+			l.DPanic(context.Background(), "invariant broken", nil)
+			return false
+		}()
+		if panicked != dev {
+			t.Errorf("development=%v: panicked = %v", dev, panicked)
+		}
+		if !strings.Contains(buf.String(), "invariant broken") {
+			t.Errorf("development=%v: entry not written", dev)
+		}
+	}
+}
+
+func TestGetLevelFallback(t *testing.T) {
+	if got := getLevel(42); got != zapcore.InfoLevel {
+		t.Errorf("getLevel(42) = %v, want info", got)
+	}
+	if got := getLevel(1); got != zapcore.FatalLevel {
+		t.Errorf("getLevel(1) = %v, want fatal", got)
+	}
+}
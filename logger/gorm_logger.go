@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"go.uber.org/zap/zapcore"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	gormLogger "gorm.io/gorm/logger"
@@ -15,13 +19,52 @@ import (
 type ormLogger struct {
 	logger.Config
 	nanosecondsToMilliseconds float64
+	slowQueries               *SlowQueryRecorder
+	log                       Log
 }
 
+var _ gorm.ParamsFilter = ormLogger{}
+
 type Option func(c *ormLogger)
 
+// WithSlowThreshold logs the statements slower than threshold, such as
+// 200*time.Millisecond, as slow. A zero threshold disables the slow log.
 func WithSlowThreshold(threshold time.Duration) Option {
 	return func(c *ormLogger) {
-		c.SlowThreshold = threshold * time.Millisecond
+		c.SlowThreshold = threshold
+	}
+}
+
+// WithIgnoreRecordNotFoundError skips the error log of statements failing
+// with gorm.ErrRecordNotFound.
+func WithIgnoreRecordNotFoundError(ignore bool) Option {
+	return func(c *ormLogger) {
+		c.IgnoreRecordNotFoundError = ignore
+	}
+}
+
+// WithParameterizedQueries logs the statements with placeholders instead of
+// their parameters.
+func WithParameterizedQueries(parameterized bool) Option {
+	return func(c *ormLogger) {
+		c.ParameterizedQueries = parameterized
+	}
+}
+
+// WithSlowQueryRecorder feeds the statements slower than the slow threshold
+// to r. Add r to the db with db.Use to also log the plans of the slow
+// SELECT statements.
+func WithSlowQueryRecorder(r *SlowQueryRecorder) Option {
+	return func(c *ormLogger) {
+		c.slowQueries = r
+	}
+}
+
+// WithLogger writes the gorm logs to l under the name "gorm". Without it the
+// logger carried by the context, or the global one, is used.
+func WithLogger(l Log) Option {
+	return func(c *ormLogger) {
+		c.log = l.WithName("gorm").WithDepth(ormLoggerDepth)
 	}
 }
 
@@ -30,6 +73,7 @@ func WithLogLevel(level logger.LogLevel) Option {
 		c.LogLevel = level
 	}
 }
+
 func WithColorful(colorful bool) Option {
 	return func(c *ormLogger) {
 		c.Colorful = colorful
@@ -60,17 +104,79 @@ func (l *ormLogger) LogMode(level logger.LogLevel) logger.Interface {
 
 // Info print info
 func (l ormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
-	Info(ctx, msg, data...)
+	if l.LogLevel >= gormLogger.Info {
+		l.print(ctx, zapcore.InfoLevel, gormUtils.FileWithLineNum(), fmt.Sprintf(msg, data...), nil, nil)
+	}
 }
 
 // Warn print warn messages
 func (l ormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
-	Warn(ctx, msg, nil, data...)
+	if l.LogLevel >= gormLogger.Warn {
+		l.print(ctx, zapcore.WarnLevel, gormUtils.FileWithLineNum(), fmt.Sprintf(msg, data...), nil, nil)
+	}
 }
 
 // Error print error messages
 func (l ormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
-	Error(ctx, msg, nil, data...)
+	if l.LogLevel >= gormLogger.Error {
+		l.print(ctx, zapcore.ErrorLevel, gormUtils.FileWithLineNum(), fmt.Sprintf(msg, data...), nil, nil)
+	}
+}
+
+// ormLoggerDepth skips print and the ormLogger method calling it, so that
+// a logger without explicit callers reports the gorm frame.
+const ormLoggerDepth = 2
+
+// logger returns the Log of l, nil when neither l, ctx nor the global Logger
+// has one.
+func (l ormLogger) logger(ctx context.Context) Log {
+	if l.log != nil {
+		return l.log
+	}
+	if log := FromContext(ctx); log != nil {
+		return log.WithName("gorm").WithDepth(ormLoggerDepth)
+	}
+	return nil
+}
+
+// print logs msg attributing it to caller, the file:line of the statement
+// in the repository as reported by gormUtils.FileWithLineNum. It must be
+// computed by the method gorm calls, which is skipped by FileWithLineNum.
+func (l ormLogger) print(ctx context.Context, lvl zapcore.Level, caller, msg string, err error, fields []any) {
+	log := l.logger(ctx)
+	if log == nil {
+		return
+	}
+	if cl, ok := log.(callerLogger); ok {
+		if i := strings.LastIndexByte(caller, ':'); i > 0 {
+			if line, convErr := strconv.Atoi(caller[i+1:]); convErr == nil {
+				cl.logAt(ctx, zapcore.NewEntryCaller(0, caller[:i], line, true), lvl, msg, err, fields)
+				return
+			}
+		}
+	}
+	if caller != "" {
+		fields = append(fields, "caller", caller)
+	}
+	switch lvl {
+	case zapcore.DebugLevel:
+		log.Debug(ctx, msg, fields...)
+	case zapcore.InfoLevel:
+		log.Info(ctx, msg, fields...)
+	case zapcore.WarnLevel:
+		log.Warn(ctx, msg, err, fields...)
+	default:
+		log.Error(ctx, msg, err, fields...)
+	}
+}
+
+// ParamsFilter drops the parameters of the traced statements when
+// ParameterizedQueries is set.
+func (l ormLogger) ParamsFilter(ctx context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.ParameterizedQueries {
+		return sql, nil
+	}
+	return sql, params
 }
 
 func (l ormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
@@ -78,49 +184,73 @@ func (l ormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string
 		return
 	}
 	elapsed := time.Since(begin)
+	caller := gormUtils.FileWithLineNum()
 	switch {
 	case err != nil && l.LogLevel >= gormLogger.Error &&
 		(!errors.Is(err, gorm.ErrRecordNotFound) || !l.IgnoreRecordNotFoundError):
 		sql, rows := fc()
-		if rows == -1 {
-			Error(ctx, "sql exec detail", err, "gorm",
-				gormUtils.FileWithLineNum(), "elapsed time",
-				fmt.Sprintf("%f(ms)", float64(elapsed.Nanoseconds())/l.nanosecondsToMilliseconds),
-				"sql", sql)
-		} else {
-			Error(ctx, "sql exec detail", err, "gorm",
-				gormUtils.FileWithLineNum(), "elapsed time",
-				fmt.Sprintf("%f(ms)", float64(elapsed.Nanoseconds())/l.nanosecondsToMilliseconds),
-				"rows", rows, "sql", sql)
-		}
+		l.print(ctx, zapcore.ErrorLevel, caller, "sql exec detail", err, l.traceFields(ctx, elapsed, sql, rows))
 	case elapsed > l.SlowThreshold && l.SlowThreshold != 0 && l.LogLevel >= gormLogger.Warn:
 		sql, rows := fc()
-		slowLog := fmt.Sprintf("SLOW SQL >= %v", l.SlowThreshold)
-		if rows == -1 {
-			Warn(ctx, "sql exec detail", nil, "gorm",
-				gormUtils.FileWithLineNum(), "slow sql",
-				slowLog, "elapsed time",
-				fmt.Sprintf("%f(ms)",
-					float64(elapsed.Nanoseconds())/l.nanosecondsToMilliseconds), "sql", sql)
-		} else {
-			Warn(ctx, "sql exec detail", nil, "gorm",
-				gormUtils.FileWithLineNum(), "slow sql",
-				slowLog, "elapsed time",
-				fmt.Sprintf("%f(ms)", float64(elapsed.Nanoseconds())/l.nanosecondsToMilliseconds),
-				"rows", rows, "sql", sql)
+		fields := append(l.traceFields(ctx, elapsed, sql, rows),
+			"slow_threshold_ms", float64(l.SlowThreshold.Nanoseconds())/l.nanosecondsToMilliseconds)
+		l.print(ctx, zapcore.WarnLevel, caller, "slow sql", nil, fields)
+		if l.slowQueries != nil {
+			l.slowQueries.Record(sql, elapsed)
 		}
 	case l.LogLevel == gormLogger.Info:
 		sql, rows := fc()
-		if rows == -1 {
-			Debug(ctx, "sql exec detail", "gorm",
-				gormUtils.FileWithLineNum(), "elapsed time", fmt.Sprintf("%f(ms)",
-					float64(elapsed.Nanoseconds())/l.nanosecondsToMilliseconds), "sql", sql)
-		} else {
-			Debug(ctx, "sql exec detail", "gorm",
-				gormUtils.FileWithLineNum(), "elapsed time",
-				fmt.Sprintf("%f(ms)",
-					float64(elapsed.Nanoseconds())/l.nanosecondsToMilliseconds),
-				"rows", rows, "sql", sql)
-		}
+		l.print(ctx, zapcore.DebugLevel, caller, "sql exec detail", nil, l.traceFields(ctx, elapsed, sql, rows))
+	}
+}
+
+// traceFields returns the structured fields of a traced statement, rows is
+// left out when gorm does not know the number of affected rows and txID
+// outside of a transaction tracked by the TxPlugin.
+func (l ormLogger) traceFields(ctx context.Context, elapsed time.Duration, sql string, rows int64) []any {
+	operation, table := parseSQL(sql)
+	fields := []any{
+		"elapsed_ms", float64(elapsed.Nanoseconds()) / l.nanosecondsToMilliseconds,
+		"sql", sql,
+		"operation", operation,
+		"table", table,
+	}
+	if rows != -1 {
+		fields = append(fields, "rows", rows)
+	}
+	if txID := TxID(ctx); txID != "" {
+		fields = append(fields, "txID", txID)
+	}
+	return fields
+}
+
+var (
+	sqlKeyword  = regexp.MustCompile(`(?s)^\s*(?:/\*.*?\*/\s*|--[^\n]*\n\s*)*([A-Za-z]+)`)
+	sqlIdent    = "([\\w$.`\"\\[\\]]+)"
+	sqlTableRes = map[string]*regexp.Regexp{
+		"SELECT":  regexp.MustCompile(`(?is)\bFROM\s+` + sqlIdent),
+		"INSERT":  regexp.MustCompile(`(?is)\bINTO\s+` + sqlIdent),
+		"REPLACE": regexp.MustCompile(`(?is)\bINTO\s+` + sqlIdent),
+		"UPDATE":  regexp.MustCompile(`(?is)\bUPDATE\s+(?:LOW_PRIORITY\s+|IGNORE\s+|ONLY\s+)*` + sqlIdent),
+		"DELETE":  regexp.MustCompile(`(?is)\bFROM\s+` + sqlIdent),
+	}
+)
+
+// parseSQL returns the upper-cased leading keyword of sql, such as SELECT,
+// INSERT, UPDATE or DELETE, and the first table it reads or writes. The
+// table is empty when it can not be told from the statement.
+func parseSQL(sql string) (operation, table string) {
+	m := sqlKeyword.FindStringSubmatch(sql)
+	if m == nil {
+		return "", ""
+	}
+	operation = strings.ToUpper(m[1])
+	re, ok := sqlTableRes[operation]
+	if !ok {
+		return operation, ""
+	}
+	if m := re.FindStringSubmatch(sql); m != nil {
+		table = strings.NewReplacer("`", "", `"`, "", "[", "", "]", "").Replace(m[1])
 	}
+	return operation, table
 }
@@ -0,0 +1,176 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func TestParseSQL(t *testing.T) {
+	tests := []struct {
+		sql, operation, table string
+	}{
+		{"SELECT * FROM `users` WHERE `users`.`id` = 1", "SELECT", "users"},
+		{"select count(*) from (select id from orders) t", "SELECT", "orders"},
+		{`INSERT INTO "public"."orders" ("id") VALUES (1)`, "INSERT", "public.orders"},
+		{"/* trace */ UPDATE `users` SET `name`='bob'", "UPDATE", "users"},
+		{"DELETE FROM [dbo].[items] WHERE id = 2", "DELETE", "dbo.items"},
+		{"CREATE TABLE foo (id int)", "CREATE", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		op, table := parseSQL(tt.sql)
+		if op != tt.operation || table != tt.table {
+			t.Errorf("parseSQL(%q) = %q, %q, want %q, %q", tt.sql, op, table, tt.operation, tt.table)
+		}
+	}
+}
+
+func TestGormTraceFields(t *testing.T) {
+	zl, logs := newObservedLogger(zapcore.DebugLevel)
+	ctx := IntoContext(context.Background(), zl)
+	l := NewGormLogger(WithLogLevel(gormLogger.Info))
+
+	l.Trace(ctx, time.Now().Add(-2*time.Millisecond), func() (string, int64) {
+		return "SELECT * FROM `users`", 3
+	}, nil)
+	l.Trace(ctx, time.Now(), func() (string, int64) {
+		return "DELETE FROM `users`", -1
+	}, errors.New("locked"))
+
+	entries := logs.AllUntimed()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	f := entries[0].ContextMap()
+	if ms, ok := f["elapsed_ms"].(float64); !ok || ms < 2 {
+		t.Errorf("elapsed_ms = %#v", f["elapsed_ms"])
+	}
+	if f["rows"] != int64(3) || f["operation"] != "SELECT" || f["table"] != "users" {
+		t.Errorf("fields = %v", f)
+	}
+	f = entries[1].ContextMap()
+	if _, ok := f["rows"]; ok || entries[1].Level != zapcore.ErrorLevel || f["operation"] != "DELETE" {
+		t.Errorf("error entry = %v %v", entries[1].Level, f)
+	}
+}
+
+type optionUser struct {
+	ID   uint
+	Name string
+}
+
+func openObservedDB(t *testing.T, opt ...Option) (*gorm.DB, *observer.ObservedLogs) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(opt...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Session(&gorm.Session{Logger: gormLogger.Discard}).AutoMigrate(&optionUser{}); err != nil {
+		t.Fatal(err)
+	}
+	zl, logs := newObservedLogger(zapcore.DebugLevel)
+	return db.WithContext(IntoContext(context.Background(), zl)), logs
+}
+
+func TestGormSlowThreshold(t *testing.T) {
+	threshold := NewGormLogger(WithSlowThreshold(200 * time.Millisecond)).(*ormLogger).SlowThreshold
+	if threshold != 200*time.Millisecond {
+		t.Fatalf("slow threshold = %s, want 200ms", threshold)
+	}
+
+	db, logs := openObservedDB(t, WithLogLevel(gormLogger.Warn), WithSlowThreshold(time.Hour))
+	db.Create(&optionUser{Name: "fast"})
+	if logs.Len() != 0 {
+		t.Errorf("fast statement logged: %v", logs.All())
+	}
+
+	db, logs = openObservedDB(t, WithLogLevel(gormLogger.Warn), WithSlowThreshold(time.Nanosecond))
+	db.Create(&optionUser{Name: "slow"})
+	if logs.FilterMessage("slow sql").Len() != 1 {
+		t.Errorf("slow statement not logged: %v", logs.All())
+	}
+}
+
+func TestGormIgnoreRecordNotFound(t *testing.T) {
+	for _, ignore := range []bool{false, true} {
+		db, logs := openObservedDB(t, WithLogLevel(gormLogger.Warn), WithIgnoreRecordNotFoundError(ignore))
+		var u optionUser
+		if err := db.First(&u, 42).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Fatalf("First = %v", err)
+		}
+		if got, want := logs.FilterLevelExact(zapcore.ErrorLevel).Len(), map[bool]int{false: 1, true: 0}[ignore]; got != want {
+			t.Errorf("ignore=%v: logged %d errors, want %d", ignore, got, want)
+		}
+	}
+}
+
+func TestGormParameterizedQueries(t *testing.T) {
+	for _, parameterized := range []bool{false, true} {
+		db, logs := openObservedDB(t, WithLogLevel(gormLogger.Info), WithParameterizedQueries(parameterized))
+		db.Where("name = ?", "secret-name").Find(&[]optionUser{})
+		entries := logs.FilterMessage("sql exec detail").All()
+		if len(entries) != 1 {
+			t.Fatalf("logged %d statements, want 1", len(entries))
+		}
+		sql, _ := entries[0].ContextMap()["sql"].(string)
+		if strings.Contains(sql, "secret-name") == parameterized {
+			t.Errorf("parameterized=%v: sql = %q", parameterized, sql)
+		}
+	}
+}
+
+func TestGormLoggerCaller(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&LoggerConifg{
+		LogLevel: LevelDebug,
+		Sinks:    []SinkConfig{{Type: SinkWriter, Writer: &out, Encoding: EncodingJSON}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(WithLogger(l))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+
+	db.Find(&[]optionUser{}) // the line reported as caller
+	_, _, line, _ := runtime.Caller(0)
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if want := fmt.Sprintf("logger/gorm_logger_test.go:%d", line-1); entry["caller"] != want {
+		t.Errorf("caller = %v, want %s", entry["caller"], want)
+	}
+	if entry["logger"] != "gorm" || entry["level"] != "ERROR" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	out.Reset()
+	silent := db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormLogger.Silent)})
+	silent.Logger.Info(context.Background(), "hidden %d", 1)
+	silent.Logger.Error(context.Background(), "hidden %d", 2)
+	db.Logger.LogMode(gormLogger.Error).Warn(context.Background(), "hidden %d", 3)
+	if out.Len() != 0 {
+		t.Errorf("logged below the log mode: %s", out.String())
+	}
+	db.Logger.Warn(context.Background(), "shown %d", 4)
+	if !strings.Contains(out.String(), `"msg":"shown 4`) {
+		t.Errorf("warn = %s", out.String())
+	}
+}
@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/yunbaifan/pkg/imcontext"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	MetricQueryDuration = "gorm_query_duration_seconds"
+	MetricQueryErrors   = "gorm_query_errors_total"
+	MetricRowsAffected  = "gorm_rows_affected_total"
+
+	pluginStartKey   = "logger:plugin_start"
+	pluginSpanKey    = "logger:plugin_span"
+	pluginContextKey = "logger:plugin_context"
+)
+
+// DefaultDurationBuckets are the buckets of the query duration histogram in
+// seconds.
+var DefaultDurationBuckets = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// GormPlugin is a gorm.Plugin recording the duration, errors and affected
+// rows of every statement per operation and table, and wrapping each
+// statement in an OpenTelemetry span carrying the imcontext operation ID.
+type GormPlugin struct {
+	metrics MetricsRegistry
+	tracer  trace.Tracer
+
+	duration HistogramVec
+	errors   CounterVec
+	rows     CounterVec
+}
+
+var _ gorm.Plugin = (*GormPlugin)(nil)
+
+type PluginOption func(p *GormPlugin)
+
+// WithPluginMetrics records the statement metrics in reg.
+func WithPluginMetrics(reg MetricsRegistry) PluginOption {
+	return func(p *GormPlugin) {
+		p.metrics = reg
+	}
+}
+
+// WithTracerProvider starts the spans with tp instead of the global
+// provider.
+func WithTracerProvider(tp trace.TracerProvider) PluginOption {
+	return func(p *GormPlugin) {
+		p.tracer = tp.Tracer("github.com/yunbaifan/pkg/logger")
+	}
+}
+
+func NewGormPlugin(opt ...PluginOption) *GormPlugin {
+	p := &GormPlugin{}
+	for _, o := range opt {
+		o(p)
+	}
+	if p.tracer == nil {
+		p.tracer = otel.Tracer("github.com/yunbaifan/pkg/logger")
+	}
+	if p.metrics != nil {
+		p.duration = p.metrics.Histogram(MetricQueryDuration, "Duration of the gorm statements in seconds.",
+			DefaultDurationBuckets, "operation", "table")
+		p.errors = p.metrics.Counter(MetricQueryErrors, "Number of failed gorm statements.",
+			"operation", "table")
+		p.rows = p.metrics.Counter(MetricRowsAffected, "Number of rows affected by gorm statements.",
+			"operation", "table")
+	}
+	return p
+}
+
+func (p *GormPlugin) Name() string {
+	return "logger:plugin"
+}
+
+func (p *GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after func(name string, fn func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("logger:before_"+h.operation, p.before(h.operation)); err != nil {
+			return err
+		}
+		if err := h.after("logger:after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *GormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		attrs := []attribute.KeyValue{
+			attribute.String("db.system", db.Dialector.Name()),
+			attribute.String("db.operation", operation),
+		}
+		if operationID := imcontext.GetOperation(ctx); operationID != "" {
+			attrs = append(attrs, attribute.String("operationID", operationID))
+		}
+		ctx, span := p.tracer.Start(ctx, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+		db.InstanceSet(pluginContextKey, db.Statement.Context)
+		db.InstanceSet(pluginSpanKey, span)
+		db.InstanceSet(pluginStartKey, time.Now())
+		db.Statement.Context = ctx
+	}
+}
+
+func (p *GormPlugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(pluginStartKey)
+		if !ok {
+			return
+		}
+		elapsed := time.Since(v.(time.Time))
+		sql := db.Statement.SQL.String()
+		table := db.Statement.Table
+		if table == "" {
+			_, table = parseSQL(sql)
+		}
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+
+		if p.metrics != nil {
+			p.duration.Observe(elapsed.Seconds(), operation, table)
+			if err != nil {
+				p.errors.Inc(operation, table)
+			}
+			if db.RowsAffected > 0 {
+				p.rows.Add(float64(db.RowsAffected), operation, table)
+			}
+		}
+
+		if v, ok := db.InstanceGet(pluginSpanKey); ok {
+			span := v.(trace.Span)
+			span.SetAttributes(
+				attribute.String("db.statement", sql),
+				attribute.String("db.sql.table", table),
+				attribute.Int64("db.rows_affected", db.RowsAffected),
+			)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+			span.End()
+		}
+		// the following statements of a reused session belong to the parent span
+		if v, ok := db.InstanceGet(pluginContextKey); ok {
+			db.Statement.Context = v.(context.Context)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/yunbaifan/pkg/imcontext"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+type pluginUser struct {
+	ID   uint
+	Name string
+}
+
+func TestGormPlugin(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	reg := NewMemoryRegistry()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Use(NewGormPlugin(WithPluginMetrics(reg),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&pluginUser{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := imcontext.WithOperation(context.Background(), "op-1")
+	tx := db.WithContext(ctx)
+	if err := tx.Create(&[]pluginUser{{Name: "a"}, {Name: "b"}}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var users []pluginUser
+	if err := tx.Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Exec("UPDATE missing SET x = 1").Error; err == nil {
+		t.Fatal("update of a missing table succeeded")
+	}
+
+	if v := reg.CounterValue(MetricRowsAffected, "create", "plugin_users"); v != 2 {
+		t.Errorf("created rows = %v, want 2", v)
+	}
+	if n, _ := reg.HistogramCount(MetricQueryDuration, "query", "plugin_users"); n != 1 {
+		t.Errorf("query durations = %d, want 1", n)
+	}
+	if v := reg.CounterValue(MetricQueryErrors, "raw", "missing"); v != 1 {
+		t.Errorf("raw errors = %v, want 1", v)
+	}
+
+	var found bool
+	for _, s := range spans.Ended() {
+		if s.Name() != "gorm.create" {
+			continue
+		}
+		found = true
+		attrs := map[string]string{}
+		for _, a := range s.Attributes() {
+			attrs[string(a.Key)] = a.Value.Emit()
+		}
+		if attrs["operationID"] != "op-1" || attrs["db.sql.table"] != "plugin_users" || attrs["db.rows_affected"] != "2" {
+			t.Errorf("create span attributes = %v", attrs)
+		}
+	}
+	if !found {
+		t.Errorf("no create span in %d spans", len(spans.Ended()))
+	}
+}
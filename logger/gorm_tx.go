@@ -0,0 +1,256 @@
+package logger
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrTxPrepareStmt is returned when a TxPlugin is used with a db opened with
+// PrepareStmt.
+var ErrTxPrepareStmt = errors.New("logger: TxPlugin does not support PrepareStmt")
+
+type txIDKey struct{}
+
+// WithTxID returns a copy of ctx carrying the transaction ID id.
+func WithTxID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, txIDKey{}, id)
+}
+
+// TxID returns the transaction ID carried by ctx, or "" outside of a
+// transaction started on a db using the TxPlugin.
+func TxID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(txIDKey{}).(string)
+	return id
+}
+
+func newTxID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// TxPlugin is a gorm.Plugin giving every transaction begun through
+// db.Begin or db.Transaction an ID. The ID is added to the context of the
+// statements run in the transaction, where the gorm logger reports it as
+// "txID", and a summary line is logged when the transaction ends.
+//
+// It wraps the connection pool of the db and does not support a db opened
+// with PrepareStmt, Initialize fails with ErrTxPrepareStmt on such a db.
+// Sessions with PrepareStmt are supported.
+type TxPlugin struct {
+	log Log
+
+	mu   sync.Mutex
+	ends []func(ctx context.Context, id, outcome string, err error)
+}
+
+var (
+	_ gorm.Plugin = (*TxPlugin)(nil)
+	_ gorm.Tx     = (*txPool)(nil)
+)
+
+// NewTxPlugin returns a TxPlugin logging the summaries to l, or to the logger
+// of the transaction context when l is nil.
+func NewTxPlugin(l Log) *TxPlugin {
+	p := &TxPlugin{}
+	if l != nil {
+		p.log = l.WithName("gorm")
+	}
+	return p
+}
+
+// onEnd adds fn to the functions called with the context, ID, outcome and
+// error of every transaction when it ends.
+func (p *TxPlugin) onEnd(fn func(ctx context.Context, id, outcome string, err error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ends = append(p.ends, fn)
+}
+
+func (p *TxPlugin) Name() string {
+	return "logger:tx"
+}
+
+func (p *TxPlugin) Initialize(db *gorm.DB) error {
+	switch db.ConnPool.(type) {
+	case *gorm.PreparedStmtDB:
+		return ErrTxPrepareStmt
+	case *txConnPool:
+		// already registered
+		return nil
+	}
+	pool := &txConnPool{ConnPool: db.ConnPool, plugin: p}
+	db.ConnPool = pool
+	db.Statement.ConnPool = pool
+
+	cb := db.Callback()
+	registers := map[string]func(name string, fn func(*gorm.DB)) error{
+		"create": cb.Create().Before("*").Register,
+		"query":  cb.Query().Before("*").Register,
+		"update": cb.Update().Before("*").Register,
+		"delete": cb.Delete().Before("*").Register,
+		"row":    cb.Row().Before("*").Register,
+		"raw":    cb.Raw().Before("*").Register,
+	}
+	for operation, register := range registers {
+		if err := register("logger:tx_"+operation, injectTxID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// injectTxID adds the ID of the transaction the statement runs in to its
+// context.
+func injectTxID(db *gorm.DB) {
+	pool := db.Statement.ConnPool
+	// a session with PrepareStmt wraps the transaction
+	if prepared, ok := pool.(*gorm.PreparedStmtTX); ok {
+		pool = prepared.Tx
+	}
+	if tx, ok := pool.(*txPool); ok && TxID(db.Statement.Context) != tx.id {
+		db.Statement.Context = WithTxID(db.Statement.Context, tx.id)
+	}
+}
+
+// txConnPool wraps the connection pool of a db so that the transactions it
+// begins are tracked by a txPool.
+type txConnPool struct {
+	gorm.ConnPool
+	plugin *TxPlugin
+}
+
+func (c *txConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := c.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		err = gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+	id := newTxID()
+	return &txPool{ConnPool: tx, id: id, ctx: WithTxID(ctx, id), begin: time.Now(), plugin: c.plugin}, nil
+}
+
+// GetDBConn returns the *sql.DB of the wrapped pool for db.DB().
+func (c *txConnPool) GetDBConn() (*sql.DB, error) {
+	switch pool := c.ConnPool.(type) {
+	case *sql.DB:
+		return pool, nil
+	case gorm.GetDBConnector:
+		return pool.GetDBConn()
+	}
+	return nil, gorm.ErrInvalidDB
+}
+
+// txPool is the connection pool of one transaction, it counts the
+// statements run in it and logs a summary on commit or rollback.
+type txPool struct {
+	gorm.ConnPool
+	id         string
+	ctx        context.Context
+	begin      time.Time
+	plugin     *TxPlugin
+	statements atomic.Int64
+	once       sync.Once
+}
+
+func (t *txPool) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	t.statements.Add(1)
+	return t.ConnPool.ExecContext(ctx, query, args...)
+}
+
+func (t *txPool) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	t.statements.Add(1)
+	return t.ConnPool.QueryContext(ctx, query, args...)
+}
+
+func (t *txPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	t.statements.Add(1)
+	return t.ConnPool.QueryRowContext(ctx, query, args...)
+}
+
+// StmtContext returns stmt bound to the transaction, it is how the statements
+// of a session with PrepareStmt run in the transaction.
+func (t *txPool) StmtContext(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	t.statements.Add(1)
+	if tx, ok := t.ConnPool.(gorm.Tx); ok {
+		return tx.StmtContext(ctx, stmt)
+	}
+	return stmt
+}
+
+func (t *txPool) Commit() error {
+	committer, ok := t.ConnPool.(gorm.TxCommitter)
+	if !ok {
+		return gorm.ErrInvalidTransaction
+	}
+	err := committer.Commit()
+	t.end("commit", err)
+	return err
+}
+
+func (t *txPool) Rollback() error {
+	committer, ok := t.ConnPool.(gorm.TxCommitter)
+	if !ok {
+		return gorm.ErrInvalidTransaction
+	}
+	err := committer.Rollback()
+	t.end("rollback", err)
+	return err
+}
+
+// end reports the outcome of the transaction once, a rollback following a
+// failed commit is not reported again.
+func (t *txPool) end(outcome string, err error) {
+	t.once.Do(func() {
+		t.plugin.mu.Lock()
+		ends := t.plugin.ends
+		t.plugin.mu.Unlock()
+		for _, fn := range ends {
+			fn(t.ctx, t.id, outcome, err)
+		}
+		t.summary(outcome, err)
+	})
+}
+
+// summary logs the outcome of the transaction.
+func (t *txPool) summary(outcome string, err error) {
+	log := t.plugin.log
+	if log == nil {
+		if log = FromContext(t.ctx); log == nil {
+			return
+		}
+		log = log.WithName("gorm")
+	}
+	fields := []any{
+		"txID", t.id,
+		"outcome", outcome,
+		"statements", t.statements.Load(),
+		"elapsed_ms", durationMillis(time.Since(t.begin)),
+	}
+	if err != nil {
+		log.Warn(t.ctx, "transaction "+outcome+" failed", err, fields...)
+		return
+	}
+	log.Info(t.ctx, "transaction "+outcome, fields...)
+}
@@ -0,0 +1,133 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func TestTxPlugin(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(WithLogLevel(gormLogger.Info))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(NewTxPlugin(nil)); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection of an in-memory database is a new database
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&optionUser{}); err != nil {
+		t.Fatal(err)
+	}
+
+	zl, logs := newObservedLogger(zapcore.DebugLevel)
+	ctx := IntoContext(context.Background(), zl)
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&optionUser{Name: "a"}).Error; err != nil {
+			return err
+		}
+		return tx.Find(&[]optionUser{}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := logs.FilterMessage("sql exec detail").All()
+	if len(statements) != 2 {
+		t.Fatalf("logged %d statements, want 2", len(statements))
+	}
+	txID, _ := statements[0].ContextMap()["txID"].(string)
+	if txID == "" || statements[1].ContextMap()["txID"] != txID {
+		t.Fatalf("statement tx IDs = %v, %v", statements[0].ContextMap()["txID"], statements[1].ContextMap()["txID"])
+	}
+	commits := logs.FilterMessage("transaction commit").All()
+	if len(commits) != 1 {
+		t.Fatalf("logged %d commit summaries, want 1", len(commits))
+	}
+	if f := commits[0].ContextMap(); f["txID"] != txID || f["statements"] != int64(2) || f["outcome"] != "commit" {
+		t.Errorf("commit summary = %v", f)
+	}
+
+	logs.TakeAll()
+	errAbort := errors.New("abort")
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx.Create(&optionUser{Name: "b"})
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Transaction = %v", err)
+	}
+	rollbacks := logs.FilterMessage("transaction rollback").All()
+	if len(rollbacks) != 1 || rollbacks[0].ContextMap()["txID"] == txID {
+		t.Fatalf("rollback summaries = %v", rollbacks)
+	}
+
+	logs.TakeAll()
+	db.WithContext(ctx).Find(&[]optionUser{})
+	if _, ok := logs.All()[0].ContextMap()["txID"]; ok {
+		t.Errorf("statement outside of a transaction has a tx ID")
+	}
+}
+
+func TestTxPluginPrepareStmt(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{PrepareStmt: true, Logger: gormLogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(NewTxPlugin(nil)); !errors.Is(err, ErrTxPrepareStmt) {
+		t.Errorf("Use = %v, want ErrTxPrepareStmt", err)
+	}
+}
+
+func TestTxPluginSessionPrepareStmt(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(WithLogLevel(gormLogger.Info))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(NewTxPlugin(nil)); err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&optionUser{}); err != nil {
+		t.Fatal(err)
+	}
+
+	zl, logs := newObservedLogger(zapcore.DebugLevel)
+	session := db.WithContext(IntoContext(context.Background(), zl)).Session(&gorm.Session{PrepareStmt: true})
+	for i := 0; i < 2; i++ {
+		err = session.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&optionUser{Name: "a"}).Error; err != nil {
+				return err
+			}
+			return tx.Find(&[]optionUser{}).Error
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, e := range logs.FilterMessage("sql exec detail").All() {
+		if e.ContextMap()["txID"] == nil {
+			t.Errorf("statement without txID: %v", e.ContextMap()["sql"])
+		}
+	}
+	commits := logs.FilterMessage("transaction commit").All()
+	if len(commits) != 2 || commits[1].ContextMap()["statements"] != int64(2) {
+		t.Fatalf("commit summaries = %v", commits)
+	}
+	var n int64
+	if err := db.Model(&optionUser{}).Count(&n).Error; err != nil || n != 2 {
+		t.Errorf("count = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,164 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// levelRegistry holds the level shared by a logger and everything derived
+// from it through WithValues/WithName/WithDepth, plus per-name overrides.
+type levelRegistry struct {
+	base  zap.AtomicLevel
+	floor zap.AtomicLevel
+	mu    sync.RWMutex
+	named map[string]zapcore.Level
+}
+
+var _ zapcore.LevelEnabler = (*levelRegistry)(nil)
+
+func newLevelRegistry(level zapcore.Level) *levelRegistry {
+	return &levelRegistry{
+		base:  zap.NewAtomicLevelAt(level),
+		floor: zap.NewAtomicLevelAt(level),
+		named: make(map[string]zapcore.Level),
+	}
+}
+
+// Enabled reports whether any logger sharing the registry may log at lvl, it
+// is used by the zap cores so that named overrides below the base level are
+// not filtered out before reaching them.
+func (r *levelRegistry) Enabled(lvl zapcore.Level) bool {
+	return r.floor.Enabled(lvl)
+}
+
+func (r *levelRegistry) enabledFor(name string, lvl zapcore.Level) bool {
+	return lvl >= r.level(name)
+}
+
+// level returns the effective level of the named logger, the override of the
+// nearest named ancestor wins over the base level.
+func (r *levelRegistry) level(name string) zapcore.Level {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for n := name; n != ""; {
+		if lvl, ok := r.named[n]; ok {
+			return lvl
+		}
+		i := strings.LastIndexByte(n, '.')
+		if i < 0 {
+			break
+		}
+		n = n[:i]
+	}
+	return r.base.Level()
+}
+
+func (r *levelRegistry) setLevel(name string, lvl zapcore.Level) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		r.base.SetLevel(lvl)
+	} else {
+		r.named[name] = lvl
+	}
+	r.updateFloor()
+}
+
+func (r *levelRegistry) unsetLevel(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.named, name)
+	r.updateFloor()
+}
+
+func (r *levelRegistry) updateFloor() {
+	floor := r.base.Level()
+	for _, lvl := range r.named {
+		if lvl < floor {
+			floor = lvl
+		}
+	}
+	r.floor.SetLevel(floor)
+}
+
+func joinName(parent, name string) string {
+	switch {
+	case parent == "":
+		return name
+	case name == "":
+		return parent
+	default:
+		return parent + "." + name
+	}
+}
+
+type levelPayload struct {
+	Logger string `json:"logger,omitempty"`
+	Level  string `json:"level"`
+}
+
+type levelHandler struct {
+	l Log
+}
+
+// NewLevelHandler returns an http.Handler that reports the level of l on GET
+// and changes it on PUT. The optional "logger" query parameter or JSON field
+// addresses a named child of l (as created by WithName), a PUT with an empty
+// level removes the override of that child.
+//
+//	curl -X PUT -d '{"logger":"gorm","level":"debug"}' localhost:8080/log/level
+func NewLevelHandler(l Log) http.Handler {
+	return &levelHandler{l: l}
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var payload levelPayload
+	switch r.Method {
+	case http.MethodGet:
+		payload.Logger = r.URL.Query().Get("logger")
+	case http.MethodPut:
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			h.error(w, http.StatusBadRequest, "request body must be JSON: "+err.Error())
+			return
+		}
+		if payload.Logger == "" {
+			payload.Logger = r.URL.Query().Get("logger")
+		}
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		h.error(w, http.StatusMethodNotAllowed, "only GET and PUT are supported")
+		return
+	}
+	l := h.l
+	if payload.Logger != "" {
+		l = l.WithName(payload.Logger)
+	}
+	if r.Method == http.MethodPut {
+		if payload.Level == "" && payload.Logger != "" {
+			if u, ok := l.(interface{ UnsetLevel() }); ok {
+				u.UnsetLevel()
+			}
+		} else {
+			var lvl zapcore.Level
+			if err := lvl.UnmarshalText([]byte(payload.Level)); err != nil {
+				h.error(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			l.SetLevel(lvl)
+		}
+	}
+	payload.Level = l.GetLevel().String()
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func (h *levelHandler) error(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
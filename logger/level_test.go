@@ -0,0 +1,77 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newObservedLogger(level zapcore.Level) (*zapLogger, *observer.ObservedLogs) {
+	zl := &zapLogger{levels: newLevelRegistry(level), closer: &closer{}}
+	core, logs := observer.New(zl.levels)
+	zl.state = &swapState{}
+	errEnc, _ := newErrorEncoding(nil)
+	zl.state.store(coreGeneration{core: core, errors: errEnc})
+	zl.zap = zap.New(core).Sugar()
+	return zl, logs
+}
+
+func TestNamedLevelOverride(t *testing.T) {
+	ctx := context.Background()
+	root, logs := newObservedLogger(zapcore.InfoLevel)
+	gorm := root.WithName("gorm")
+
+	gorm.Debug(ctx, "hidden")
+	if logs.Len() != 0 {
+		t.Fatalf("debug entry logged below the base level")
+	}
+
+	gorm.SetLevel(zapcore.DebugLevel)
+	gorm.Debug(ctx, "shown")
+	gorm.WithName("child").Debug(ctx, "inherited")
+	root.Debug(ctx, "root hidden")
+	if got := logs.Len(); got != 2 {
+		t.Fatalf("logged %d entries, want 2", got)
+	}
+	if root.GetLevel() != zapcore.InfoLevel {
+		t.Errorf("root level changed to %v", root.GetLevel())
+	}
+
+	root.SetLevel(zapcore.ErrorLevel)
+	root.Warn(ctx, "dropped", nil)
+	if got := logs.Len(); got != 2 {
+		t.Fatalf("warn logged after raising root level")
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	root, _ := newObservedLogger(zapcore.InfoLevel)
+	h := NewLevelHandler(root)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"logger":"gorm","level":"debug"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, body %s", rec.Code, rec.Body)
+	}
+	if got := root.WithName("gorm").GetLevel(); got != zapcore.DebugLevel {
+		t.Errorf("gorm level = %v, want debug", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(rec.Body.String(), `"level":"info"`) {
+		t.Errorf("GET body = %s, want info level", rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"level":"loud"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid level status = %d, want 400", rec.Code)
+	}
+}
@@ -2,7 +2,7 @@ package logger
 
 import (
 	"context"
-	"os"
+	"fmt"
 	"path/filepath"
 	"sync"
 	"time"
@@ -18,9 +18,26 @@ type Log interface {
 	Info(ctx context.Context, msg string, fields ...any)
 	Warn(ctx context.Context, msg string, err error, fields ...any)
 	Error(ctx context.Context, msg string, err error, fields ...any)
+	// DPanic logs at DPanicLevel and panics if the logger is in development
+	// mode.
+	DPanic(ctx context.Context, msg string, err error, fields ...any)
+	// Panic logs at PanicLevel and then panics.
+	Panic(ctx context.Context, msg string, err error, fields ...any)
+	// Fatal logs at FatalLevel, flushes and closes the logger and then calls
+	// the exit function set by SetExitFunc, os.Exit by default.
+	Fatal(ctx context.Context, msg string, err error, fields ...any)
 	WithValues(fields ...any) Log
 	WithName(name string) Log
 	WithDepth(depth int) Log
+	// Sync flushes any buffered entries.
+	Sync() error
+	// Close flushes and releases the files held by the logger. Loggers
+	// derived from it share those files and must not be used afterwards.
+	Close() error
+	// SetLevel changes the minimum level at runtime. On a logger derived
+	// through WithName it overrides the level for that name only.
+	SetLevel(level zapcore.Level)
+	GetLevel() zapcore.Level
 }
 
 var (
@@ -31,21 +48,37 @@ var (
 )
 
 type LoggerConifg struct {
-	LogPrefixName string        `json:"logPrefixName" yaml:"logPrefixName" default:"go-mall" description:"日志文件前缀名"`
-	ModuleName    string        `json:"moduleName" yaml:"moduleName" default:"go-mall" description:"模块名称"`
-	LogLevel      int           `json:"logLevel" yaml:"logLevel" default:"info" description:"日志级别"`
-	IsStdout      bool          `json:"isStdout" yaml:"isStdout" default:"true" description:"是否输出到终端"`
-	IsJson        bool          `json:"isJson" yaml:"isJson" default:"true" description:"是否输出为json格式"`
-	Location      string        `json:"logLocation" yaml:"logLocation" default:"logs/*.log" description:"日志文件路径"`
-	RotateCount   uint          `json:"rotateCount" yaml:"rotateCount" default:"7" description:"日志文件最大保存天数"`
-	RotationTime  time.Duration `json:"rotationTime" yaml:"rotationTime" default:"24" description:"日志文件最大保存天数"`
-	Version       string        `json:"version" yaml:"version" default:"v1.0.0" description:"版本号"`
-	PId           int           `json:"pid" yaml:"pid" default:"0" description:"进程ID"`
+	LogPrefixName string           `json:"logPrefixName" yaml:"logPrefixName" default:"go-mall" description:"日志文件前缀名"`
+	ModuleName    string           `json:"moduleName" yaml:"moduleName" default:"go-mall" description:"模块名称"`
+	LogLevel      Level            `json:"logLevel" yaml:"logLevel" default:"info" description:"日志级别"`
+	IsStdout      bool             `json:"isStdout" yaml:"isStdout" default:"true" description:"是否输出到终端"`
+	IsJson        bool             `json:"isJson" yaml:"isJson" default:"true" description:"是否输出为json格式"`
+	Location      string           `json:"logLocation" yaml:"logLocation" default:"logs" description:"日志文件目录"`
+	RotateCount   uint             `json:"rotateCount" yaml:"rotateCount" default:"7" description:"日志文件最大保存天数"`
+	RotationTime  int              `json:"rotationTime" yaml:"rotationTime" default:"24" description:"日志文件切割间隔小时数"`
+	Version       string           `json:"version" yaml:"version" default:"v1.0.0" description:"版本号"`
+	PId           int              `json:"pid" yaml:"pid" default:"0" description:"进程ID"`
+	Async         *AsyncConfig     `json:"async" yaml:"async" description:"异步写日志配置, 为空时同步写"`
+	Sampling      *SamplingConfig  `json:"sampling" yaml:"sampling" description:"日志采样配置"`
+	RateLimit     *RateLimitConfig `json:"rateLimit" yaml:"rateLimit" description:"按消息限流配置"`
+	Redact        *RedactConfig    `json:"redact" yaml:"redact" description:"敏感字段脱敏配置"`
+	Sinks         []SinkConfig     `json:"sinks" yaml:"sinks" description:"日志输出列表, 为空时按 logLocation/isStdout 输出"`
+	Errors        *ErrorConfig     `json:"errors" yaml:"errors" description:"错误链和错误堆栈的输出配置"`
+	Development   bool             `json:"development" yaml:"development" default:"false" description:"开发模式, DPanic 级别日志会触发 panic"`
+	MaxSize       int              `json:"maxSize" yaml:"maxSize" default:"0" description:"单个日志文件最大大小(MB), 0 表示不按大小切割"`
+	Compress      string           `json:"compress" yaml:"compress" default:"" description:"切割后日志文件的压缩方式: gzip/zstd"`
+	MaxAge        int              `json:"maxAge" yaml:"maxAge" default:"0" description:"日志文件最大保存小时数"`
+	MaxTotalSize  int              `json:"maxTotalSize" yaml:"maxTotalSize" default:"0" description:"日志文件最大总大小(MB)"`
+	// Metrics receives the number of entries by level, logger name and
+	// module, the bytes written to the outputs for each entry and the entries
+	// dropped by the async writers when set.
+	Metrics MetricsRegistry `json:"-" yaml:"-"`
 }
 
 type zapLogger struct {
 	zap          *zap.SugaredLogger
-	level        zapcore.Level
+	levels       *levelRegistry
+	named        string
 	name         string
 	preName      string
 	rotationTime time.Duration
@@ -53,6 +86,11 @@ type zapLogger struct {
 	PId          int
 	version      string
 	plugin       PluginLogger
+	closer       *closer
+	async        []*AsyncWriter
+	redactor     *redactor
+	entrySize    HistogramVec
+	state        *swapState
 }
 
 func getLevel(level int) zapcore.Level {
@@ -65,45 +103,121 @@ func getLevel(level int) zapcore.Level {
 		1: zapcore.FatalLevel,
 		0: zapcore.PanicLevel,
 	}
-	return maps[level]
+	if lvl, ok := maps[level]; ok {
+		return lvl
+	}
+	return zapcore.InfoLevel
 }
 
-func NewZapLogger(cfg *LoggerConifg) (Log, error) {
-	if Logger == nil {
-		mu.Lock()
-		defer mu.Unlock()
-		if Logger == nil {
-			zapConfig := zap.Config{
-				Level:             zap.NewAtomicLevelAt(getLevel(cfg.LogLevel)),
-				DisableStacktrace: true,
-			}
-			if cfg.IsJson {
-				zapConfig.Encoding = "json"
-			} else {
-				zapConfig.Encoding = "console"
-			}
-			zl := &zapLogger{
-				level:        getLevel(cfg.LogLevel),
-				name:         cfg.ModuleName,
-				version:      cfg.Version,
-				preName:      cfg.LogPrefixName,
-				rotationTime: cfg.RotationTime * time.Hour,
-				layout:       "2006-01-02 15:04:05",
-				PId:          cfg.PId,
-				plugin:       NewPlugin(),
-			}
-			opts, err := zl.core(cfg.IsStdout, cfg.IsJson, cfg.Location, cfg.RotateCount)
-			if err != nil {
-				return nil, err
-			}
-			l, err := zapConfig.Build(opts)
-			if err != nil {
-				return nil, err
-			}
-			zl.zap = l.Sugar()
-			Logger = zl
+// New builds an independent Log from cfg. Unlike NewZapLogger it neither
+// reads nor replaces the global Logger, use SetDefault to install it.
+func New(cfg *LoggerConifg) (Log, error) {
+	zapConfig := zap.Config{
+		DisableStacktrace: true,
+		Development:       cfg.Development,
+	}
+	if cfg.IsJson {
+		zapConfig.Encoding = "json"
+	} else {
+		zapConfig.Encoding = "console"
+	}
+	zl := newZapLogger(cfg, newLevelRegistry(getLevel(int(cfg.LogLevel))))
+	zapConfig.Level = zl.levels.base
+	gen, err := zl.build(cfg)
+	if err != nil {
+		zl.closer.close()
+		return nil, err
+	}
+	zl.state = &swapState{}
+	zl.state.store(gen)
+	l, err := zapConfig.Build(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+		return &swapCore{state: zl.state}
+	}), zap.WithFatalHook(exitHook{zl}))
+	if err != nil {
+		zl.closer.close()
+		return nil, err
+	}
+	zl.zap = l.Sugar()
+	return zl, nil
+}
+
+func newZapLogger(cfg *LoggerConifg, levels *levelRegistry) *zapLogger {
+	return &zapLogger{
+		levels:       levels,
+		name:         cfg.ModuleName,
+		version:      cfg.Version,
+		preName:      cfg.LogPrefixName,
+		rotationTime: time.Duration(cfg.RotationTime) * time.Hour,
+		layout:       "2006-01-02 15:04:05",
+		PId:          cfg.PId,
+		plugin:       NewPlugin(),
+		closer:       &closer{},
+	}
+}
+
+// build opens the outputs described by cfg and returns the core writing to
+// them, the opened resources are registered in z.closer.
+func (z *zapLogger) build(cfg *LoggerConifg) (coreGeneration, error) {
+	errEnc, err := newErrorEncoding(cfg.Errors)
+	if err != nil {
+		return coreGeneration{}, err
+	}
+	if cfg.Redact != nil {
+		r, err := newRedactor(cfg.Redact)
+		if err != nil {
+			return coreGeneration{}, err
 		}
+		z.redactor = r
+	}
+	core, err := z.core(cfg)
+	if err != nil {
+		return coreGeneration{}, err
+	}
+	return coreGeneration{core: core, async: z.async, errors: errEnc}, nil
+}
+
+// Reconfigure rebuilds the outputs of the logger from cfg and switches every
+// logger derived from it to them, then closes the previous outputs once the
+// entries already checked against them are written. The level is reset to
+// cfg.LogLevel, named overrides are kept.
+func (z *zapLogger) Reconfigure(cfg *LoggerConifg) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	nz := newZapLogger(cfg, z.levels)
+	gen, err := nz.build(cfg)
+	if err != nil {
+		nz.closer.close()
+		return err
+	}
+	z.levels.setLevel("", getLevel(int(cfg.LogLevel)))
+	z.state.store(gen).writes.retire(retireTimeout)
+	return z.closer.replace(nz.closer).close()
+}
+
+// SetDefault installs l as the global Logger used by the package-level
+// functions when the context carries no logger.
+func SetDefault(l Log) {
+	mu.Lock()
+	defer mu.Unlock()
+	Logger = l
+}
+
+// NewZapLogger builds a Log from cfg and installs it as the global Logger. If
+// a global Logger already exists it is returned and cfg is ignored.
+//
+// Deprecated: use New and SetDefault.
+func NewZapLogger(cfg *LoggerConifg) (Log, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if Logger != nil {
+		return Logger, nil
 	}
+	l, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	Logger = l
 	return Logger, nil
 }
 
@@ -120,75 +234,117 @@ func (z *zapLogger) timeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder)
 
 }
 
-func (z *zapLogger) core(isStdout, isJson bool, logLoction string, rotateCount uint) (zap.Option, error) {
-	c := zap.NewProductionEncoderConfig()
-	c.EncodeTime = z.timeEncoder
-	c.EncodeDuration = zapcore.SecondsDurationEncoder
-	c.MessageKey = "msg"
-	c.LevelKey = "level"
-	c.TimeKey = "time"
-	c.NameKey = "logger"
-	var fileEncoder zapcore.Encoder
-	if isJson {
-		c.EncodeLevel = zapcore.CapitalLevelEncoder
-		fileEncoder = zapcore.NewJSONEncoder(c)
-		fileEncoder.AddInt("PID", os.Getegid())
-		fileEncoder.AddString("version", z.version)
-	} else {
-		c.EncodeLevel = z.plugin.CapitalColorLevelEncoder
-		c.EncodeCaller = z.plugin.CustomCallerEncoder
-		fileEncoder = zapcore.NewConsoleEncoder(c)
+func (z *zapLogger) core(cfg *LoggerConifg) (zapcore.Core, error) {
+	if cfg.Metrics != nil {
+		z.entrySize = newEntrySize(cfg.Metrics)
 	}
-	fileEncoder = &alignEncoder{fileEncoder}
-	writer, err := z.getWriter(logLoction, rotateCount)
-	if err != nil {
-		return nil, err
+	var cores []zapcore.Core
+	for _, sink := range cfg.sinks() {
+		core, err := z.sinkCore(cfg, sink)
+		if err != nil {
+			return nil, err
+		}
+		cores = append(cores, core)
 	}
-	var (
-		cores []zapcore.Core
-	)
-	if logLoction != "" {
-		cores = []zapcore.Core{
-			zapcore.NewCore(fileEncoder, writer, zap.NewAtomicLevelAt(z.level)),
+	tee := zapcore.NewTee(cores...)
+	if cfg.RateLimit != nil {
+		limited, err := newRateLimitCore(tee, cfg.RateLimit)
+		if err != nil {
+			return nil, err
 		}
+		z.closer.add(limited.limiter)
+		tee = limited
 	}
-	if isStdout {
-		cores = append(cores,
-			zapcore.NewCore(fileEncoder, zapcore.Lock(os.Stdout),
-				zap.NewAtomicLevelAt(z.level),
-			))
+	if cfg.Sampling != nil {
+		sampled, err := newSamplingCore(tee, cfg.Sampling)
+		if err != nil {
+			return nil, err
+		}
+		tee = sampled
+	}
+	if cfg.Metrics != nil {
+		tee = newMetricsCore(tee, cfg.Metrics, cfg.ModuleName)
 	}
-	return zap.WrapCore(func(core zapcore.Core) zapcore.Core {
-		return zapcore.NewTee(cores...)
-	}), nil
+	return tee, nil
 }
 
-func (z *zapLogger) getWriter(logLocation string, rotateCount uint) (zapcore.WriteSyncer, error) {
-	var path string
-	if z.rotationTime%(time.Hour*time.Duration(24)) == 0 {
+// newCore builds the core writing to ws, wrapping ws in an AsyncWriter when
+// cfg.Async is set and masking sensitive values when cfg.Redact is set.
+func (z *zapLogger) newCore(cfg *LoggerConifg, enc zapcore.Encoder, ws zapcore.WriteSyncer, level zapcore.LevelEnabler) (zapcore.Core, error) {
+	ioCore := func(ws zapcore.WriteSyncer) zapcore.Core {
+		if z.entrySize != nil {
+			return &sizeCore{LevelEnabler: level, enc: enc, out: ws, size: z.entrySize}
+		}
+		return zapcore.NewCore(enc, ws, level)
+	}
+	var core zapcore.Core
+	if cfg.Async == nil {
+		core = ioCore(ws)
+	} else {
+		aw, err := NewAsyncWriter(ws, *cfg.Async)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Metrics != nil {
+			aw.countDropped(cfg.Metrics.Counter(MetricEntriesDropped,
+				"Number of log entries discarded by the async overflow policy.", "module", "overflow"),
+				cfg.ModuleName, aw.overflow)
+		}
+		z.closer.add(aw)
+		z.async = append(z.async, aw)
+		core = &syncOnLevelCore{
+			Core:  ioCore(aw),
+			ws:    aw,
+			level: zapcore.ErrorLevel,
+		}
+	}
+	if z.redactor != nil {
+		core = &redactCore{Core: core, r: z.redactor}
+	}
+	return core, nil
+}
 
+func (z *zapLogger) getWriter(cfg *LoggerConifg, logLocation, preName string) (zapcore.WriteSyncer, error) {
+	if cfg.MaxSize > 0 || cfg.Compress != CompressNone || cfg.MaxAge > 0 || cfg.MaxTotalSize > 0 {
+		w, err := NewRotateWriter(RotateConfig{
+			Dir:          logLocation,
+			Prefix:       preName,
+			RotationTime: z.rotationTime,
+			MaxSize:      int64(cfg.MaxSize) * megabyte,
+			Compress:     cfg.Compress,
+			MaxBackups:   cfg.RotateCount,
+			MaxAge:       time.Duration(cfg.MaxAge) * time.Hour,
+			MaxTotalSize: int64(cfg.MaxTotalSize) * megabyte,
+		})
+		if err != nil {
+			return nil, err
+		}
+		z.closer.add(w)
+		return w, nil
 	}
+	var path string
 	switch {
 	case z.rotationTime%(time.Hour*time.Duration(24)) == 0:
-		path = logLocation + sp + z.preName + ".%Y-%m-%d.log"
+		path = logLocation + sp + preName + ".%Y-%m-%d.log"
 	case z.rotationTime%time.Hour == 0:
-		path = logLocation + sp + z.preName + ".%Y-%m-%d_%H"
+		path = logLocation + sp + preName + ".%Y-%m-%d_%H"
 	default:
-		path = logLocation + sp + z.preName + ".%Y-%m-%d_%H-%M-%S"
+		path = logLocation + sp + preName + ".%Y-%m-%d_%H-%M-%S"
 	}
 	logf, err := rotatelogs.New(
 		path,
-		rotatelogs.WithRotationCount(rotateCount),
+		rotatelogs.WithRotationCount(cfg.RotateCount),
 		rotatelogs.WithRotationTime(z.rotationTime),
 	)
 	if err != nil {
 		return nil, err
 	}
+	z.closer.add(logf)
 	return zapcore.AddSync(logf), nil
 }
 
 func (z *zapLogger) Debug(ctx context.Context, msg string, fields ...any) {
-	if z.level > zapcore.DebugLevel {
+	if !z.levels.enabledFor(z.named, zapcore.DebugLevel) {
 		return
 	}
 	kv := z.AppendString(ctx, fields)
@@ -196,7 +352,7 @@ func (z *zapLogger) Debug(ctx context.Context, msg string, fields ...any) {
 }
 
 func (z *zapLogger) Info(ctx context.Context, msg string, fields ...any) {
-	if z.level > zapcore.InfoLevel {
+	if !z.levels.enabledFor(z.named, zapcore.InfoLevel) {
 		return
 	}
 	kv := z.AppendString(ctx, fields)
@@ -204,28 +360,106 @@ func (z *zapLogger) Info(ctx context.Context, msg string, fields ...any) {
 }
 
 func (z *zapLogger) Warn(ctx context.Context, msg string, err error, fields ...any) {
-	if z.level > zapcore.WarnLevel {
+	if !z.levels.enabledFor(z.named, zapcore.WarnLevel) {
 		return
 	}
 	if err != nil {
-		fields = append(fields, "error", err.Error())
+		fields = append(fields, z.state.load().errors.fields(zapcore.WarnLevel, err)...)
 	}
 	kv := z.AppendString(ctx, fields)
 	z.zap.Warnw(msg, kv...)
 }
 
 func (z *zapLogger) Error(ctx context.Context, msg string, err error, fields ...any) {
-	if z.level > zapcore.ErrorLevel {
+	if !z.levels.enabledFor(z.named, zapcore.ErrorLevel) {
 		return
 	}
 	if err != nil {
-		fields = append(fields, "error", err.Error())
+		fields = append(fields, z.state.load().errors.fields(zapcore.ErrorLevel, err)...)
 	}
 	kv := z.AppendString(ctx, fields)
 	z.zap.Errorw(msg, kv...)
 }
 
+func (z *zapLogger) DPanic(ctx context.Context, msg string, err error, fields ...any) {
+	if !z.levels.enabledFor(z.named, zapcore.DPanicLevel) {
+		return
+	}
+	if err != nil {
+		fields = append(fields, z.state.load().errors.fields(zapcore.DPanicLevel, err)...)
+	}
+	kv := z.AppendString(ctx, fields)
+	z.zap.DPanicw(msg, kv...)
+}
+
+func (z *zapLogger) Panic(ctx context.Context, msg string, err error, fields ...any) {
+	if err != nil {
+		fields = append(fields, z.state.load().errors.fields(zapcore.PanicLevel, err)...)
+	}
+	kv := z.AppendString(ctx, fields)
+	z.zap.Panicw(msg, kv...)
+}
+
+func (z *zapLogger) Fatal(ctx context.Context, msg string, err error, fields ...any) {
+	if err != nil {
+		fields = append(fields, z.state.load().errors.fields(zapcore.FatalLevel, err)...)
+	}
+	kv := z.AppendString(ctx, fields)
+	z.zap.Fatalw(msg, kv...)
+}
+
+// callerLogger is implemented by the Log values able to attribute an entry
+// to an explicit caller instead of the frame calling them.
+type callerLogger interface {
+	logAt(ctx context.Context, caller zapcore.EntryCaller, lvl zapcore.Level, msg string, err error, fields []any)
+}
+
+func (z *zapLogger) logAt(ctx context.Context, caller zapcore.EntryCaller, lvl zapcore.Level, msg string, err error, fields []any) {
+	if !z.levels.enabledFor(z.named, lvl) {
+		return
+	}
+	if err != nil {
+		fields = append(fields, z.state.load().errors.fields(lvl, err)...)
+	}
+	kv := z.AppendString(ctx, fields)
+	ce := z.zap.Desugar().Check(lvl, msg)
+	if ce == nil {
+		return
+	}
+	ce.Caller = caller
+	ce.Write(sweeten(kv)...)
+}
+
+// sweeten converts alternating keys and values to fields the way the
+// SugaredLogger does.
+func sweeten(kv []any) []zap.Field {
+	fields := make([]zap.Field, 0, len(kv)/2)
+	for i := 0; i < len(kv); {
+		if f, ok := kv[i].(zap.Field); ok {
+			fields = append(fields, f)
+			i++
+			continue
+		}
+		if i == len(kv)-1 {
+			fields = append(fields, zap.Any("ignored", kv[i]))
+			break
+		}
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		fields = append(fields, zap.Any(key, kv[i+1]))
+		i += 2
+	}
+	return fields
+}
+
 func (z *zapLogger) AppendString(ctx context.Context, kv []any) []any {
+	return appendContext(ctx, kv)
+}
+
+// appendContext prepends the imcontext values carried by ctx to kv.
+func appendContext(ctx context.Context, kv []any) []any {
 	if operationID := imcontext.GetOperation(ctx); operationID != "" {
 		kv = append([]any{"operationID", operationID}, kv...)
 	}
@@ -255,6 +489,7 @@ func (z *zapLogger) WithValues(fields ...any) Log {
 func (l *zapLogger) WithName(name string) Log {
 	dup := *l
 	dup.zap = l.zap.Named(name)
+	dup.named = joinName(l.named, name)
 	return &dup
 }
 
@@ -263,3 +498,37 @@ func (l *zapLogger) WithDepth(depth int) Log {
 	dup.zap = l.zap.WithOptions(zap.AddCallerSkip(depth))
 	return &dup
 }
+
+func (z *zapLogger) SetLevel(level zapcore.Level) {
+	z.levels.setLevel(z.named, level)
+}
+
+func (z *zapLogger) GetLevel() zapcore.Level {
+	return z.levels.level(z.named)
+}
+
+// UnsetLevel removes the override set through SetLevel on a named logger, so
+// that it follows its parent again.
+func (z *zapLogger) UnsetLevel() {
+	if z.named != "" {
+		z.levels.unsetLevel(z.named)
+	}
+}
+
+func (z *zapLogger) Sync() error {
+	return z.zap.Sync()
+}
+
+func (z *zapLogger) Close() error {
+	return z.closer.close(z.zap.Sync())
+}
+
+// DroppedEntries returns the number of entries discarded by the async
+// writers because their buffer was full.
+func (z *zapLogger) DroppedEntries() uint64 {
+	var n uint64
+	for _, aw := range z.state.load().async {
+		n += aw.Dropped()
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewIsIndependent(t *testing.T) {
+	ctx := context.Background()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	a, err := New(&LoggerConifg{LogPrefixName: "a", LogLevel: 4, IsJson: true, Location: dirA, RotationTime: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(&LoggerConifg{LogPrefixName: "b", LogLevel: 4, IsJson: true, Location: dirB, RotationTime: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Logger == a || Logger == b {
+		t.Fatal("New installed the logger globally")
+	}
+	a.Info(ctx, "to a")
+	b.Info(ctx, "to b")
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	for dir, want := range map[string]string{dirA: "to a", dirB: "to b"} {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+		if len(files) != 1 {
+			t.Fatalf("%s: got files %v", dir, files)
+		}
+		data, err := os.ReadFile(files[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s: %q missing from %s", dir, want, data)
+		}
+	}
+}
+
+// blockingObject blocks while it is encoded until release is closed.
+type blockingObject struct {
+	once     sync.Once
+	encoding chan struct{}
+	release  chan struct{}
+}
+
+func (o *blockingObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	o.once.Do(func() { close(o.encoding) })
+	<-o.release
+	enc.AddString("k", "v")
+	return nil
+}
+
+func TestReconfigureWaitsForPendingWrites(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := func(prefix string) *LoggerConifg {
+		return &LoggerConifg{LogPrefixName: prefix, LogLevel: 4, IsJson: true, Location: dir, RotationTime: 24,
+			Async: &AsyncConfig{}}
+	}
+	l, err := New(cfg("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	obj := &blockingObject{encoding: make(chan struct{}), release: make(chan struct{})}
+	logged := make(chan struct{})
+	go func() {
+		l.Info(ctx, "pending", "obj", obj)
+		close(logged)
+	}()
+	<-obj.encoding
+	reconfigured := make(chan error, 1)
+	go func() {
+		reconfigured <- l.(*zapLogger).Reconfigure(cfg("b"))
+	}()
+	select {
+	case <-reconfigured:
+		t.Fatal("Reconfigure returned while an entry was being written to the old outputs")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(obj.release)
+	<-logged
+	if err := <-reconfigured; err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "a.*.log"))
+	if len(files) != 1 {
+		t.Fatalf("files = %v, want one file with the old prefix", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "pending") {
+		t.Errorf("old file = %s, want the pending entry", data)
+	}
+}
@@ -0,0 +1,265 @@
+// Package logtest provides a logger.Log that records entries in memory, and
+// helpers to assert on them in tests.
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yunbaifan/pkg/imcontext"
+	"github.com/yunbaifan/pkg/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is one recorded log call.
+type Entry struct {
+	Level   zapcore.Level
+	Name    string
+	Message string
+	Err     error
+	// Fields holds the key/value pairs passed to the call and to WithValues.
+	Fields map[string]any
+	// Context holds the imcontext values found in the context of the call,
+	// keyed like the fields added by the zap logger, e.g. "operationID".
+	Context map[string]string
+}
+
+type store struct {
+	mu      sync.Mutex
+	entries []Entry
+	level   zap.AtomicLevel
+}
+
+// Recorder is a logger.Log keeping every entry in memory. Loggers derived
+// through WithValues/WithName record into the same Recorder.
+type Recorder struct {
+	s      *store
+	name   string
+	values []any
+}
+
+var _ logger.Log = (*Recorder)(nil)
+
+// New returns a Recorder recording every level.
+func New() *Recorder {
+	return &Recorder{s: &store{level: zap.NewAtomicLevelAt(zapcore.DebugLevel)}}
+}
+
+// Install installs a new Recorder as the global logger.Logger for the
+// duration of the test and returns it.
+func Install(t testing.TB) *Recorder {
+	t.Helper()
+	r := New()
+	prev := logger.Logger
+	logger.SetDefault(r)
+	t.Cleanup(func() { logger.SetDefault(prev) })
+	return r
+}
+
+func (r *Recorder) record(ctx context.Context, lvl zapcore.Level, msg string, err error, kv []any) {
+	if !r.s.level.Enabled(lvl) {
+		return
+	}
+	e := Entry{
+		Level:   lvl,
+		Name:    r.name,
+		Message: msg,
+		Err:     err,
+		Fields:  make(map[string]any),
+		Context: contextValues(ctx),
+	}
+	addFields(e.Fields, r.values)
+	addFields(e.Fields, kv)
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.entries = append(r.s.entries, e)
+}
+
+func addFields(m map[string]any, kv []any) {
+	for i := 0; i < len(kv); i += 2 {
+		key := fmt.Sprint(kv[i])
+		if i+1 < len(kv) {
+			m[key] = kv[i+1]
+		} else {
+			m[key] = nil
+		}
+	}
+}
+
+func contextValues(ctx context.Context) map[string]string {
+	m := make(map[string]string)
+	if ctx == nil {
+		return m
+	}
+	for key, get := range map[string]func(context.Context) string{
+		"operationID":    imcontext.GetOperation,
+		"opUserID":       imcontext.GetOpUserID,
+		"opUserPlatform": imcontext.GetOpUserPlatform,
+		"connID":         imcontext.GetConnID,
+		"triggerID":      imcontext.GetTriggerID,
+		"remoteAddr":     imcontext.GetRemoteAddr,
+	} {
+		if v := get(ctx); v != "" {
+			m[key] = v
+		}
+	}
+	return m
+}
+
+func (r *Recorder) Debug(ctx context.Context, msg string, fields ...any) {
+	r.record(ctx, zapcore.DebugLevel, msg, nil, fields)
+}
+
+func (r *Recorder) Info(ctx context.Context, msg string, fields ...any) {
+	r.record(ctx, zapcore.InfoLevel, msg, nil, fields)
+}
+
+func (r *Recorder) Warn(ctx context.Context, msg string, err error, fields ...any) {
+	r.record(ctx, zapcore.WarnLevel, msg, err, fields)
+}
+
+func (r *Recorder) Error(ctx context.Context, msg string, err error, fields ...any) {
+	r.record(ctx, zapcore.ErrorLevel, msg, err, fields)
+}
+
+// DPanic records the entry, it never panics.
+func (r *Recorder) DPanic(ctx context.Context, msg string, err error, fields ...any) {
+	r.record(ctx, zapcore.DPanicLevel, msg, err, fields)
+}
+
+// Panic records the entry and panics with msg like the zap logger does.
+func (r *Recorder) Panic(ctx context.Context, msg string, err error, fields ...any) {
+	r.record(ctx, zapcore.PanicLevel, msg, err, fields)
+	panic(msg)
+}
+
+// Fatal records the entry, it does not exit.
+func (r *Recorder) Fatal(ctx context.Context, msg string, err error, fields ...any) {
+	r.record(ctx, zapcore.FatalLevel, msg, err, fields)
+}
+
+func (r *Recorder) WithValues(fields ...any) logger.Log {
+	dup := *r
+	dup.values = append(append([]any(nil), r.values...), fields...)
+	return &dup
+}
+
+func (r *Recorder) WithName(name string) logger.Log {
+	dup := *r
+	if r.name != "" {
+		name = r.name + "." + name
+	}
+	dup.name = name
+	return &dup
+}
+
+func (r *Recorder) WithDepth(int) logger.Log {
+	return r
+}
+
+func (r *Recorder) Sync() error { return nil }
+
+func (r *Recorder) Close() error { return nil }
+
+func (r *Recorder) SetLevel(level zapcore.Level) {
+	r.s.level.SetLevel(level)
+}
+
+func (r *Recorder) GetLevel() zapcore.Level {
+	return r.s.level.Level()
+}
+
+// Entries returns a copy of the recorded entries.
+func (r *Recorder) Entries() []Entry {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	return append([]Entry(nil), r.s.entries...)
+}
+
+// Reset forgets the recorded entries.
+func (r *Recorder) Reset() {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+	r.s.entries = nil
+}
+
+// Find returns the entries with the given level and message that contain
+// the key/value pairs of fields.
+func (r *Recorder) Find(level zapcore.Level, msg string, fields ...any) []Entry {
+	want := make(map[string]any)
+	addFields(want, fields)
+	var found []Entry
+	for _, e := range r.Entries() {
+		if e.Level == level && e.Message == msg && containsFields(e, want) {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func containsFields(e Entry, want map[string]any) bool {
+	for k, v := range want {
+		got, ok := e.Fields[k]
+		if !ok {
+			got, ok = e.Context[k]
+		}
+		if !ok || !reflect.DeepEqual(got, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// AssertLogged fails the test unless an entry with the given level and
+// message containing the key/value pairs of fields was recorded.
+func (r *Recorder) AssertLogged(t testing.TB, level zapcore.Level, msg string, fields ...any) {
+	t.Helper()
+	if len(r.Find(level, msg, fields...)) == 0 {
+		t.Errorf("no %s entry %q with fields %v, recorded:\n%s", level, msg, fields, r.dump())
+	}
+}
+
+// AssertNotLogged fails the test if an entry with the given level and
+// message containing the key/value pairs of fields was recorded.
+func (r *Recorder) AssertNotLogged(t testing.TB, level zapcore.Level, msg string, fields ...any) {
+	t.Helper()
+	if len(r.Find(level, msg, fields...)) != 0 {
+		t.Errorf("unexpected %s entry %q with fields %v", level, msg, fields)
+	}
+}
+
+// AssertLogged is Recorder.AssertLogged on the Recorder installed as the
+// global logger by Install.
+func AssertLogged(t testing.TB, level zapcore.Level, msg string, fields ...any) {
+	t.Helper()
+	installed(t).AssertLogged(t, level, msg, fields...)
+}
+
+// AssertNotLogged is Recorder.AssertNotLogged on the Recorder installed as
+// the global logger by Install.
+func AssertNotLogged(t testing.TB, level zapcore.Level, msg string, fields ...any) {
+	t.Helper()
+	installed(t).AssertNotLogged(t, level, msg, fields...)
+}
+
+func installed(t testing.TB) *Recorder {
+	t.Helper()
+	r, ok := logger.Logger.(*Recorder)
+	if !ok {
+		t.Fatalf("logtest: global logger is %T, call Install first", logger.Logger)
+	}
+	return r
+}
+
+func (r *Recorder) dump() string {
+	var b strings.Builder
+	for _, e := range r.Entries() {
+		fmt.Fprintf(&b, "\t%s %q fields=%v context=%v err=%v\n", e.Level, e.Message, e.Fields, e.Context, e.Err)
+	}
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package logtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/yunbaifan/pkg/imcontext"
+	"github.com/yunbaifan/pkg/logger"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestInstall(t *testing.T) {
+	r := Install(t)
+	ctx := imcontext.WithOperation(context.Background(), "op-1")
+
+	logger.Info(ctx, "user created", "uid", 7)
+	logger.FromContext(ctx).WithName("gorm").WithValues("table", "users").
+		Error(ctx, "query failed", errors.New("timeout"))
+
+	AssertLogged(t, zapcore.InfoLevel, "user created", "uid", 7, "operationID", "op-1")
+	AssertLogged(t, zapcore.ErrorLevel, "query failed", "table", "users")
+	AssertNotLogged(t, zapcore.InfoLevel, "user created", "uid", 8)
+
+	entries := r.Entries()
+	if len(entries) != 2 || entries[1].Name != "gorm" || entries[1].Err == nil {
+		t.Errorf("entries = %+v", entries)
+	}
+}
@@ -0,0 +1,236 @@
+package logger
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	MetricEntriesTotal = "log_entries_total"
+	MetricEntrySize    = "log_entry_size_bytes"
+	// MetricEntriesDropped counts the entries discarded by the overflow
+	// policy of the async writers.
+	MetricEntriesDropped = "log_entries_dropped_total"
+)
+
+// DefaultSizeBuckets are the buckets of the entry size histogram.
+var DefaultSizeBuckets = []float64{128, 256, 512, 1024, 2048, 4096, 8192, 16384}
+
+// MetricsRegistry creates the metrics reported by the logger. It is small
+// enough to be implemented on top of a Prometheus registry, MemoryRegistry
+// implements it in memory for tests.
+type MetricsRegistry interface {
+	Counter(name, help string, labelNames ...string) CounterVec
+	Histogram(name, help string, buckets []float64, labelNames ...string) HistogramVec
+}
+
+type CounterVec interface {
+	Inc(labelValues ...string)
+	Add(value float64, labelValues ...string)
+}
+
+type HistogramVec interface {
+	Observe(value float64, labelValues ...string)
+}
+
+// metricsCore counts the entries accepted by the wrapped core by level,
+// logger name and module. It must wrap the outermost core so that it only
+// counts entries that are written.
+type metricsCore struct {
+	zapcore.Core
+	module  string
+	entries CounterVec
+}
+
+func newMetricsCore(core zapcore.Core, reg MetricsRegistry, module string) *metricsCore {
+	return &metricsCore{
+		Core:   core,
+		module: module,
+		entries: reg.Counter(MetricEntriesTotal, "Number of log entries written.",
+			"level", "logger", "module"),
+	}
+}
+
+func (c *metricsCore) With(fields []zapcore.Field) zapcore.Core {
+	return &metricsCore{Core: c.Core.With(fields), module: c.module, entries: c.entries}
+}
+
+func (c *metricsCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if out := c.Core.Check(ent, ce); out != nil {
+		return out.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *metricsCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	c.entries.Inc(ent.Level.String(), ent.LoggerName, c.module)
+	return nil
+}
+
+func newEntrySize(reg MetricsRegistry) HistogramVec {
+	return reg.Histogram(MetricEntrySize, "Size of the log entries written to an output in bytes.",
+		DefaultSizeBuckets, "level")
+}
+
+// sizeCore is the zapcore.NewCore of an output that also observes the number
+// of bytes written to the output for each entry.
+type sizeCore struct {
+	zapcore.LevelEnabler
+	enc  zapcore.Encoder
+	out  zapcore.WriteSyncer
+	size HistogramVec
+}
+
+func (c *sizeCore) Level() zapcore.Level {
+	return zapcore.LevelOf(c.LevelEnabler)
+}
+
+func (c *sizeCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &sizeCore{LevelEnabler: c.LevelEnabler, enc: enc, out: c.out, size: c.size}
+}
+
+func (c *sizeCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sizeCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	n, err := c.out.Write(buf.Bytes())
+	buf.Free()
+	c.size.Observe(float64(n), ent.Level.String())
+	if err != nil {
+		return err
+	}
+	if ent.Level > zapcore.ErrorLevel {
+		// like zapcore.NewCore, sync before a panic or exit
+		return c.out.Sync()
+	}
+	return nil
+}
+
+func (c *sizeCore) Sync() error {
+	return c.out.Sync()
+}
+
+// MemoryRegistry is a MetricsRegistry keeping the values in memory.
+type MemoryRegistry struct {
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	histograms map[string]*memoryHistogram
+}
+
+var _ MetricsRegistry = (*MemoryRegistry)(nil)
+
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]*memoryHistogram),
+	}
+}
+
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+type memoryCounter struct {
+	r    *MemoryRegistry
+	name string
+}
+
+func (c memoryCounter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c memoryCounter) Add(value float64, labelValues ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.r.counters[c.name][labelKey(labelValues)] += value
+}
+
+func (r *MemoryRegistry) Counter(name, _ string, _ ...string) CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.counters[name]; !ok {
+		r.counters[name] = make(map[string]float64)
+	}
+	return memoryCounter{r: r, name: name}
+}
+
+type histogramSeries struct {
+	count   uint64
+	sum     float64
+	buckets []uint64
+}
+
+type memoryHistogram struct {
+	r       *MemoryRegistry
+	buckets []float64
+	series  map[string]*histogramSeries
+}
+
+func (h *memoryHistogram) Observe(value float64, labelValues ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	key := labelKey(labelValues)
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{buckets: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	s.count++
+	s.sum += value
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.buckets[i]++
+		}
+	}
+}
+
+func (r *MemoryRegistry) Histogram(name, _ string, buckets []float64, _ ...string) HistogramVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.histograms[name]
+	if !ok {
+		buckets = append([]float64(nil), buckets...)
+		sort.Float64s(buckets)
+		h = &memoryHistogram{r: r, buckets: buckets, series: make(map[string]*histogramSeries)}
+		r.histograms[name] = h
+	}
+	return h
+}
+
+// CounterValue returns the value of the counter with the given label values.
+func (r *MemoryRegistry) CounterValue(name string, labelValues ...string) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[name][labelKey(labelValues)]
+}
+
+// HistogramCount returns the number of observations and their sum for the
+// histogram with the given label values.
+func (r *MemoryRegistry) HistogramCount(name string, labelValues ...string) (uint64, float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h, ok := r.histograms[name]
+	if !ok {
+		return 0, 0
+	}
+	s, ok := h.series[labelKey(labelValues)]
+	if !ok {
+		return 0, 0
+	}
+	return s.count, s.sum
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	var out bytes.Buffer
+	reg := NewMemoryRegistry()
+	l, err := New(&LoggerConifg{
+		LogLevel:   LevelInfo,
+		ModuleName: "mall",
+		Sinks:      []SinkConfig{{Type: SinkWriter, Writer: &out, Encoding: EncodingJSON}},
+		Metrics:    reg,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ctx := context.Background()
+	l.Debug(ctx, "filtered")
+	l.Info(ctx, "first")
+	l.Info(ctx, "second")
+	l.WithName("db").Error(ctx, "failed", errors.New("boom"))
+
+	if v := reg.CounterValue(MetricEntriesTotal, "info", "", "mall"); v != 2 {
+		t.Errorf("info entries = %v, want 2", v)
+	}
+	if v := reg.CounterValue(MetricEntriesTotal, "error", "db", "mall"); v != 1 {
+		t.Errorf("db error entries = %v, want 1", v)
+	}
+	if v := reg.CounterValue(MetricEntriesTotal, "debug", "", "mall"); v != 0 {
+		t.Errorf("debug entries = %v, want 0", v)
+	}
+	count, sum := reg.HistogramCount(MetricEntrySize, "info")
+	errCount, errSum := reg.HistogramCount(MetricEntrySize, "error")
+	if count != 2 || errCount != 1 {
+		t.Errorf("size histogram counts = %d info, %d error, want 2 and 1", count, errCount)
+	}
+	if written := int(sum + errSum); written != out.Len() {
+		t.Errorf("size histogram sum = %d, want the %d bytes written", written, out.Len())
+	}
+}
@@ -102,7 +102,7 @@ func (p *plugin) CapitalColorLevelEncoder(level zapcore.Level, enc zapcore.Primi
 	if !ok {
 		s = p.unknownLevelColor[zapcore.ErrorLevel]
 	}
-	pid := stringutil.FormatString(fmt.Sprintf("["+"PID:"+"%d"+"]", os.Getpid), 15, true)
+	pid := stringutil.FormatString(fmt.Sprintf("["+"PID:"+"%d"+"]", os.Getpid()), 15, true)
 	color := _levelToColor[level]
 	enc.AppendString(s)
 	enc.AppendString(color.Add(s) + " " + pid)
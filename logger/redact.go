@@ -0,0 +1,320 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// SQLRedactNone logs SQL statements unchanged.
+	SQLRedactNone = ""
+	// SQLRedactMask replaces the literal values in SQL statements with the mask.
+	SQLRedactMask = "mask"
+	// SQLRedactParameterized replaces the literal values with "?" placeholders.
+	SQLRedactParameterized = "parameterized"
+
+	defaultMask = "******"
+)
+
+// Built-in patterns that can be referred to by name in RedactConfig.Patterns.
+var redactPresets = map[string]string{
+	"email": `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`,
+	"phone": `\b1[3-9]\d{9}\b`,
+	"token": `(?i)\b(?:bearer\s+[A-Za-z0-9\-._~+/]+=*|eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+)`,
+}
+
+type RedactConfig struct {
+	Keys          []string `json:"keys" yaml:"keys" description:"需要整体脱敏的字段名, 不区分大小写"`
+	Patterns      []string `json:"patterns" yaml:"patterns" description:"需要脱敏的正则表达式, 也可以使用内置的 email/phone/token"`
+	Mask          string   `json:"mask" yaml:"mask" default:"******" description:"脱敏后的替换文本"`
+	SQLKeys       []string `json:"sqlKeys" yaml:"sqlKeys" default:"sql" description:"值为 SQL 语句的字段名"`
+	SQLMode       string   `json:"sqlMode" yaml:"sqlMode" default:"" description:"SQL 脱敏方式: mask/parameterized"`
+	SQLANSIQuotes bool     `json:"sqlANSIQuotes" yaml:"sqlANSIQuotes" description:"SQL 中双引号包裹的是标识符而不是字符串, 用于 PostgreSQL"`
+}
+
+type redactor struct {
+	keys       map[string]struct{}
+	sqlKeys    map[string]struct{}
+	patterns   []*regexp.Regexp
+	mask       string
+	sqlMode    string
+	sqlLiteral *regexp.Regexp
+}
+
+var (
+	// sqlLiteral matches the literals of the statements rendered by the
+	// MySQL and SQLite dialectors, which quote strings with ' or ".
+	sqlLiteral = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*"|\b\d+(?:\.\d+)?\b`)
+	// sqlANSILiteral matches the literals of the dialects quoting identifiers
+	// with ".
+	sqlANSILiteral = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'|\b\d+(?:\.\d+)?\b`)
+)
+
+func newRedactor(cfg *RedactConfig) (*redactor, error) {
+	r := &redactor{
+		keys:       make(map[string]struct{}, len(cfg.Keys)),
+		sqlKeys:    make(map[string]struct{}, len(cfg.SQLKeys)),
+		mask:       cfg.Mask,
+		sqlMode:    cfg.SQLMode,
+		sqlLiteral: sqlLiteral,
+	}
+	if cfg.SQLANSIQuotes {
+		r.sqlLiteral = sqlANSILiteral
+	}
+	if r.mask == "" {
+		r.mask = defaultMask
+	}
+	switch r.sqlMode {
+	case SQLRedactNone, SQLRedactMask, SQLRedactParameterized:
+	default:
+		return nil, fmt.Errorf("logger: unknown sql redaction mode %q", r.sqlMode)
+	}
+	for _, k := range cfg.Keys {
+		r.keys[strings.ToLower(k)] = struct{}{}
+	}
+	sqlKeys := cfg.SQLKeys
+	if len(sqlKeys) == 0 {
+		sqlKeys = []string{"sql"}
+	}
+	for _, k := range sqlKeys {
+		r.sqlKeys[k] = struct{}{}
+	}
+	for _, p := range cfg.Patterns {
+		if preset, ok := redactPresets[p]; ok {
+			p = preset
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("logger: invalid redaction pattern %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+func (r *redactor) text(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllString(s, r.mask)
+	}
+	return s
+}
+
+// RedactSQL replaces the string and numeric literals in sql with "?". The
+// strings quoted with ' and " are literals, as rendered by the MySQL and
+// SQLite dialectors.
+func RedactSQL(sql string) string {
+	return sqlLiteral.ReplaceAllString(sql, "?")
+}
+
+func (r *redactor) sql(s string) string {
+	switch r.sqlMode {
+	case SQLRedactParameterized:
+		return r.sqlLiteral.ReplaceAllString(s, "?")
+	case SQLRedactMask:
+		return r.sqlLiteral.ReplaceAllStringFunc(s, func(lit string) string {
+			if q := lit[:1]; q == "'" || q == `"` {
+				return q + r.mask + q
+			}
+			return r.mask
+		})
+	}
+	return s
+}
+
+func (r *redactor) masked(key string) bool {
+	_, ok := r.keys[strings.ToLower(key)]
+	return ok
+}
+
+func (r *redactor) field(f zapcore.Field) zapcore.Field {
+	if r.masked(f.Key) {
+		return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: r.mask}
+	}
+	var s string
+	switch f.Type {
+	case zapcore.StringType:
+		s = f.String
+	case zapcore.ByteStringType:
+		s = string(f.Interface.([]byte))
+	case zapcore.StringerType:
+		s = fmt.Sprint(f.Interface)
+	case zapcore.ErrorType:
+		s = f.Interface.(error).Error()
+	case zapcore.ArrayMarshalerType:
+		f.Interface = redactedArray{m: f.Interface.(zapcore.ArrayMarshaler), r: r}
+		return f
+	case zapcore.ObjectMarshalerType:
+		f.Interface = redactedObject{m: f.Interface.(zapcore.ObjectMarshaler), r: r}
+		return f
+	case zapcore.ReflectType:
+		f.Interface = r.value(f.Interface)
+		return f
+	default:
+		return f
+	}
+	if _, ok := r.sqlKeys[f.Key]; ok {
+		s = r.sql(s)
+	}
+	return zapcore.Field{Key: f.Key, Type: zapcore.StringType, String: r.text(s)}
+}
+
+// value returns v with the strings it holds redacted and the values of the
+// masked keys replaced, going through its JSON form like the encoders do.
+func (r *redactor) value(v any) any {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return v
+	}
+	return r.walk(out)
+}
+
+func (r *redactor) walk(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.text(v)
+	case []any:
+		for i := range v {
+			v[i] = r.walk(v[i])
+		}
+	case map[string]any:
+		for k, e := range v {
+			if r.masked(k) {
+				v[k] = r.mask
+			} else {
+				v[k] = r.walk(e)
+			}
+		}
+	}
+	return v
+}
+
+// redactedArray redacts the elements of an array field, such as the
+// "errorChain" of an error, while it is encoded.
+type redactedArray struct {
+	m zapcore.ArrayMarshaler
+	r *redactor
+}
+
+func (a redactedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.m.MarshalLogArray(redactArrayEncoder{ArrayEncoder: enc, r: a.r})
+}
+
+type redactArrayEncoder struct {
+	zapcore.ArrayEncoder
+	r *redactor
+}
+
+func (e redactArrayEncoder) AppendString(s string) { e.ArrayEncoder.AppendString(e.r.text(s)) }
+
+func (e redactArrayEncoder) AppendByteString(b []byte) {
+	e.ArrayEncoder.AppendString(e.r.text(string(b)))
+}
+
+func (e redactArrayEncoder) AppendReflected(v any) error {
+	return e.ArrayEncoder.AppendReflected(e.r.value(v))
+}
+
+func (e redactArrayEncoder) AppendArray(m zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactedArray{m: m, r: e.r})
+}
+
+func (e redactArrayEncoder) AppendObject(m zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactedObject{m: m, r: e.r})
+}
+
+// redactedObject redacts the string values of an object field and masks the
+// values of its masked keys while it is encoded.
+type redactedObject struct {
+	m zapcore.ObjectMarshaler
+	r *redactor
+}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.m.MarshalLogObject(redactObjectEncoder{ObjectEncoder: enc, r: o.r})
+}
+
+type redactObjectEncoder struct {
+	zapcore.ObjectEncoder
+	r *redactor
+}
+
+func (e redactObjectEncoder) AddString(key, value string) {
+	if e.r.masked(key) {
+		value = e.r.mask
+	}
+	e.ObjectEncoder.AddString(key, e.r.text(value))
+}
+
+func (e redactObjectEncoder) AddByteString(key string, value []byte) {
+	e.AddString(key, string(value))
+}
+
+func (e redactObjectEncoder) AddReflected(key string, value any) error {
+	if e.r.masked(key) {
+		e.ObjectEncoder.AddString(key, e.r.mask)
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, e.r.value(value))
+}
+
+func (e redactObjectEncoder) AddArray(key string, m zapcore.ArrayMarshaler) error {
+	if e.r.masked(key) {
+		e.ObjectEncoder.AddString(key, e.r.mask)
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, redactedArray{m: m, r: e.r})
+}
+
+func (e redactObjectEncoder) AddObject(key string, m zapcore.ObjectMarshaler) error {
+	if e.r.masked(key) {
+		e.ObjectEncoder.AddString(key, e.r.mask)
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, redactedObject{m: m, r: e.r})
+}
+
+func (r *redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	if len(fields) == 0 {
+		return fields
+	}
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = r.field(f)
+	}
+	return out
+}
+
+// redactCore masks sensitive fields and message text before they reach the
+// encoder of the wrapped core. It wraps the cores of each output, since the
+// fields of a Tee cannot be changed once it has been checked.
+type redactCore struct {
+	zapcore.Core
+	r *redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.r.fields(fields)), r: c.r}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.r.text(ent.Message)
+	return c.Core.Write(ent, c.r.fields(fields))
+}
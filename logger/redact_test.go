@@ -0,0 +1,152 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func TestRedactCore(t *testing.T) {
+	r, err := newRedactor(&RedactConfig{
+		Keys:     []string{"opUserID", "remoteAddr"},
+		Patterns: []string{"email", "phone"},
+		SQLMode:  SQLRedactParameterized,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs, logs := observer.New(zapcore.DebugLevel)
+	core := (&redactCore{Core: obs, r: r}).With([]zapcore.Field{zap.String("opuserid", "u-1")})
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: "login from bob@example.com"}
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write(
+			zap.String("remoteAddr", "10.0.0.1"),
+			zap.String("note", "call 13812345678"),
+			zap.String("sql", "SELECT * FROM users WHERE email = 'bob@example.com' AND age > 18"),
+			zap.Int("rows", 1),
+		)
+	}
+	entry := logs.All()[0]
+	if entry.Message != "login from ******" {
+		t.Errorf("message = %q", entry.Message)
+	}
+	want := map[string]any{
+		"opuserid":   "******",
+		"remoteAddr": "******",
+		"note":       "call ******",
+		"sql":        "SELECT * FROM users WHERE email = ? AND age > ?",
+		"rows":       int64(1),
+	}
+	got := entry.ContextMap()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+}
+
+func TestRedactStructuredFields(t *testing.T) {
+	r, err := newRedactor(&RedactConfig{Keys: []string{"opUserID"}, Patterns: []string{"email"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obs, logs := observer.New(zapcore.DebugLevel)
+	core := &redactCore{Core: obs, r: r}
+	ent := zapcore.Entry{Level: zapcore.ErrorLevel, Message: "send failed"}
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write(
+			zap.Error(errors.New("no mailbox bob@example.com")),
+			zap.Strings("errorChain", []string{"send: no mailbox bob@example.com", "no mailbox bob@example.com"}),
+			zap.Any("detail", map[string]any{"to": "bob@example.com", "opUserID": "u-1", "tries": 3}),
+		)
+	}
+	got := logs.All()[0].ContextMap()
+	want := map[string]any{
+		"error":      "no mailbox ******",
+		"errorChain": []any{"send: no mailbox ******", "no mailbox ******"},
+		"detail":     map[string]any{"to": "******", "opUserID": "******", "tries": json.Number("3")},
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(got[k], v) {
+			t.Errorf("%s = %#v, want %#v", k, got[k], v)
+		}
+	}
+}
+
+func TestRedactorSQLMask(t *testing.T) {
+	r, err := newRedactor(&RedactConfig{SQLMode: SQLRedactMask, Mask: "#"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.sql("UPDATE t1 SET name = 'it''s', score = 9.5 WHERE id = 3")
+	if want := "UPDATE t1 SET name = '#', score = # WHERE id = #"; got != want {
+		t.Errorf("sql = %q, want %q", got, want)
+	}
+}
+
+func TestRedactorSQLDoubleQuotes(t *testing.T) {
+	sql := `SELECT * FROM users WHERE email = "alice@example.com" AND token = "s3cr3t"`
+	if got, want := RedactSQL(sql), "SELECT * FROM users WHERE email = ? AND token = ?"; got != want {
+		t.Errorf("RedactSQL = %q, want %q", got, want)
+	}
+	r, err := newRedactor(&RedactConfig{SQLMode: SQLRedactMask, Mask: "#"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := r.sql(sql), `SELECT * FROM users WHERE email = "#" AND token = "#"`; got != want {
+		t.Errorf("sql = %q, want %q", got, want)
+	}
+	r, err = newRedactor(&RedactConfig{SQLMode: SQLRedactParameterized, SQLANSIQuotes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.sql(`SELECT * FROM "users" WHERE "users"."email" = 'alice@example.com'`)
+	if want := `SELECT * FROM "users" WHERE "users"."email" = ?`; got != want {
+		t.Errorf("ANSI quotes sql = %q, want %q", got, want)
+	}
+}
+
+func TestRedactGormSQLite(t *testing.T) {
+	for _, mode := range []string{SQLRedactMask, SQLRedactParameterized} {
+		r, err := newRedactor(&RedactConfig{SQLMode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		zl, logs := newObservedLogger(zapcore.DebugLevel)
+		core := &redactCore{Core: zl.state.load().core, r: r}
+		zl.state.store(coreGeneration{core: core, errors: zl.state.load().errors})
+		zl.zap = zap.New(core).Sugar()
+
+		db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: NewGormLogger(WithLogLevel(gormLogger.Info))})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Session(&gorm.Session{Logger: gormLogger.Discard}).AutoMigrate(&optionUser{}); err != nil {
+			t.Fatal(err)
+		}
+		db = db.WithContext(IntoContext(context.Background(), zl))
+		db.Create(&optionUser{Name: "alice@example.com"})
+		db.Where("name = ?", "s3cr3t").Find(&[]optionUser{})
+
+		entries := logs.FilterMessage("sql exec detail").All()
+		if len(entries) != 2 {
+			t.Fatalf("%s: logged %d statements, want 2", mode, len(entries))
+		}
+		for _, e := range entries {
+			sql, _ := e.ContextMap()["sql"].(string)
+			if strings.Contains(sql, "alice") || strings.Contains(sql, "s3cr3t") {
+				t.Errorf("%s: sql = %s", mode, sql)
+			}
+		}
+	}
+}
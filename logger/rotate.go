@@ -0,0 +1,373 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	CompressNone = ""
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+
+	backupTimeFormat = "2006-01-02T15-04-05.000"
+	megabyte         = 1 << 20
+)
+
+// Clock is the source of time used by RotateWriter, it can be replaced in
+// tests to drive time based rotation and retention.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// RotateConfig configures a RotateWriter. The active file is written to
+// Dir/Prefix.log, rotated files are renamed to
+// Dir/Prefix.<time>.log and optionally compressed.
+type RotateConfig struct {
+	Dir    string
+	Prefix string
+	// RotationTime rotates the active file when the current time crosses a
+	// multiple of it, 0 disables time based rotation.
+	RotationTime time.Duration
+	// MaxSize rotates the active file before it grows beyond MaxSize bytes,
+	// 0 disables size based rotation.
+	MaxSize int64
+	// Compress is one of CompressNone, CompressGzip or CompressZstd.
+	Compress string
+	// MaxBackups, MaxAge and MaxTotalSize limit the rotated files that are
+	// kept, the oldest files are removed first. 0 disables a limit.
+	MaxBackups   uint
+	MaxAge       time.Duration
+	MaxTotalSize int64
+	Clock        Clock
+}
+
+// RotateWriter is an io.WriteCloser that rotates its file on time and size.
+// It is safe for concurrent use, compression and removal of old files run in
+// a background goroutine which is drained by Close.
+type RotateWriter struct {
+	cfg RotateConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	period time.Time
+	closed bool
+
+	millCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*RotateWriter)(nil)
+
+func NewRotateWriter(cfg RotateConfig) (*RotateWriter, error) {
+	switch cfg.Compress {
+	case CompressNone, CompressGzip, CompressZstd:
+	default:
+		return nil, fmt.Errorf("logger: unknown compression %q", cfg.Compress)
+	}
+	if cfg.Prefix == "" {
+		return nil, errors.New("logger: rotate writer needs a file prefix")
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = systemClock{}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &RotateWriter{
+		cfg:    cfg,
+		millCh: make(chan struct{}, 1),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.wg.Add(1)
+	go w.millRun()
+	w.mill()
+	return w, nil
+}
+
+func (w *RotateWriter) activeName() string {
+	return filepath.Join(w.cfg.Dir, w.cfg.Prefix+".log")
+}
+
+func (w *RotateWriter) truncate(t time.Time) time.Time {
+	if w.cfg.RotationTime <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(w.cfg.RotationTime)
+}
+
+// open opens the active file for appending, rotating it first if it belongs
+// to an earlier period.
+func (w *RotateWriter) open() error {
+	name := w.activeName()
+	if info, err := os.Stat(name); err == nil {
+		if w.truncate(info.ModTime()) != w.truncate(w.cfg.Clock.Now()) && info.Size() > 0 {
+			return w.rotate()
+		}
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	w.file, w.size, w.period = f, info.Size(), w.truncate(w.cfg.Clock.Now())
+	return nil
+}
+
+func (w *RotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	now := w.cfg.Clock.Now()
+	switch {
+	case w.cfg.RotationTime > 0 && w.truncate(now) != w.period,
+		w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.cfg.MaxSize:
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate renames the active file to a backup and opens a new one.
+func (w *RotateWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+	name := w.activeName()
+	if _, err := os.Stat(name); err == nil {
+		if err := os.Rename(name, w.backupName(w.cfg.Clock.Now())); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	w.file, w.size, w.period = f, 0, w.truncate(w.cfg.Clock.Now())
+	w.mill()
+	return nil
+}
+
+// backupName returns an unused backup file name for t, adding a sequence
+// number when several rotations happen within the same millisecond.
+func (w *RotateWriter) backupName(t time.Time) string {
+	stamp := t.Format(backupTimeFormat)
+	for i := 0; ; i++ {
+		base := stamp
+		if i > 0 {
+			base = fmt.Sprintf("%s-%d", stamp, i)
+		}
+		name := filepath.Join(w.cfg.Dir, w.cfg.Prefix+"."+base+".log")
+		if !exists(name) && !exists(name+".gz") && !exists(name+".zst") {
+			return name
+		}
+	}
+}
+
+func exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (w *RotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the active file and waits for pending compression and
+// cleanup to finish.
+func (w *RotateWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.file.Close()
+	close(w.millCh)
+	w.mu.Unlock()
+	w.wg.Wait()
+	return err
+}
+
+func (w *RotateWriter) mill() {
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *RotateWriter) millRun() {
+	defer w.wg.Done()
+	for range w.millCh {
+		_ = w.millRunOnce()
+	}
+}
+
+type backupFile struct {
+	name string
+	time time.Time
+	seq  int
+	size int64
+}
+
+func (w *RotateWriter) backups() ([]backupFile, error) {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := w.cfg.Prefix + "."
+	loc := w.cfg.Clock.Now().Location()
+	var files []backupFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimPrefix(name, prefix)
+		for _, ext := range []string{".gz", ".zst", ".log"} {
+			stamp = strings.TrimSuffix(stamp, ext)
+		}
+		var seq int
+		if i := strings.LastIndexByte(stamp, '-'); i > len(backupTimeFormat)-1 {
+			if _, err := fmt.Sscanf(stamp[i+1:], "%d", &seq); err == nil {
+				stamp = stamp[:i]
+			}
+		}
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, loc)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, backupFile{name: filepath.Join(w.cfg.Dir, name), time: t, seq: seq, size: info.Size()})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].time.Equal(files[j].time) {
+			return files[i].time.After(files[j].time)
+		}
+		return files[i].seq > files[j].seq
+	})
+	return files, nil
+}
+
+// millRunOnce compresses uncompressed backups and removes the backups that
+// exceed the retention limits.
+func (w *RotateWriter) millRunOnce() error {
+	files, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if w.cfg.Compress != CompressNone {
+		for i, f := range files {
+			if !strings.HasSuffix(f.name, ".log") {
+				continue
+			}
+			dst, size, err := compressFile(f.name, w.cfg.Compress)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			files[i].name, files[i].size = dst, size
+		}
+	}
+	now := w.cfg.Clock.Now()
+	var total int64
+	for i, f := range files {
+		total += f.size
+		switch {
+		case w.cfg.MaxBackups > 0 && uint(i) >= w.cfg.MaxBackups,
+			w.cfg.MaxAge > 0 && now.Sub(f.time) > w.cfg.MaxAge,
+			w.cfg.MaxTotalSize > 0 && total > w.cfg.MaxTotalSize:
+			if err := os.Remove(f.name); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile compresses src next to itself and removes src once the
+// compressed copy has been written completely.
+func compressFile(src, method string) (string, int64, error) {
+	ext := ".gz"
+	if method == CompressZstd {
+		ext = ".zst"
+	}
+	dst := src + ext
+	in, err := os.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", 0, err
+	}
+	err = func() error {
+		var zw io.WriteCloser
+		if method == CompressZstd {
+			if zw, err = zstd.NewWriter(out); err != nil {
+				return err
+			}
+		} else {
+			zw = gzip.NewWriter(out)
+		}
+		if _, err := io.Copy(zw, in); err != nil {
+			return err
+		}
+		return zw.Close()
+	}()
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return "", 0, err
+	}
+	info, err := os.Stat(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", 0, err
+	}
+	return dst, info.Size(), os.Remove(src)
+}
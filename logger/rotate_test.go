@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+}
+
+func TestRotateWriterSizeAndCompress(t *testing.T) {
+	dir, clock := t.TempDir(), newFakeClock()
+	w, err := NewRotateWriter(RotateConfig{
+		Dir:        dir,
+		Prefix:     "app",
+		MaxSize:    10,
+		Compress:   CompressGzip,
+		MaxBackups: 2,
+		Clock:      clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first-01\n", "second-2\n", "third-03\n", "fourth-4\n"} {
+		clock.Add(time.Second)
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "app.*.log.gz"))
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2 gzip files", backups)
+	}
+	if plain, _ := filepath.Glob(filepath.Join(dir, "app.*.log")); len(plain) != 0 {
+		t.Fatalf("uncompressed backups left: %v", plain)
+	}
+	f, err := os.Open(backups[len(backups)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(zr)
+	if string(data) != "third-03\n" {
+		t.Errorf("newest backup = %q, want third-03", data)
+	}
+	active, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if string(active) != "fourth-4\n" {
+		t.Errorf("active file = %q", active)
+	}
+}
+
+func TestRotateWriterTimeAndMaxAge(t *testing.T) {
+	dir, clock := t.TempDir(), newFakeClock()
+	w, err := NewRotateWriter(RotateConfig{
+		Dir:          dir,
+		Prefix:       "app",
+		RotationTime: time.Hour,
+		MaxAge:       90 * time.Minute,
+		Clock:        clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := w.Write([]byte("line\n")); err != nil {
+			t.Fatal(err)
+		}
+		clock.Add(time.Hour)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "app.*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want only the one within MaxAge", backups)
+	}
+}
+
+func TestRotateWriterConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewRotateWriter(RotateConfig{Dir: dir, Prefix: "app", MaxSize: 512, Compress: CompressZstd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				if _, err := w.Write([]byte(strings.Repeat("x", 31) + "\n")); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "app.*"))
+	if len(files) < 2 {
+		t.Fatalf("expected rotated files, got %v", files)
+	}
+	info, err := os.Stat(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 512 {
+		t.Errorf("active file grew to %d bytes", info.Size())
+	}
+}
@@ -0,0 +1,308 @@
+package logger
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	_numLevels        = zapcore.FatalLevel - zapcore.DebugLevel + 1
+	_countersPerLevel = 4096
+)
+
+// SamplingRule logs the first Initial entries with the same level and
+// message in each tick, then every Thereafter-th entry. A rule with both
+// values 0 disables sampling, Thereafter 0 drops everything after Initial.
+type SamplingRule struct {
+	Initial    int `json:"initial" yaml:"initial" description:"每个周期内先输出的条数"`
+	Thereafter int `json:"thereafter" yaml:"thereafter" description:"超过 initial 后每隔多少条输出一条"`
+}
+
+func (r SamplingRule) disabled() bool {
+	return r.Initial == 0 && r.Thereafter == 0
+}
+
+type SamplingConfig struct {
+	Tick         time.Duration `json:"tick" yaml:"tick" default:"1s" description:"采样周期"`
+	SamplingRule `yaml:",inline"`
+	// Levels overrides the rule for a level name such as "debug", Messages
+	// overrides it for an exact message and wins over Levels.
+	Levels   map[string]SamplingRule `json:"levels" yaml:"levels" description:"按日志级别设置的采样规则"`
+	Messages map[string]SamplingRule `json:"messages" yaml:"messages" description:"按日志消息设置的采样规则"`
+}
+
+func (c *SamplingConfig) UnmarshalJSON(data []byte) error {
+	type plain SamplingConfig
+	return json.Unmarshal(data, &struct {
+		*plain
+		Tick *duration `json:"tick"`
+	}{plain: (*plain)(c), Tick: (*duration)(&c.Tick)})
+}
+
+type counter struct {
+	resetAt atomic.Int64
+	n       atomic.Uint64
+}
+
+func (c *counter) incCheckReset(t time.Time, tick time.Duration) uint64 {
+	tn := t.UnixNano()
+	resetAfter := c.resetAt.Load()
+	if resetAfter > tn {
+		return c.n.Add(1)
+	}
+	c.n.Store(1)
+	if !c.resetAt.CompareAndSwap(resetAfter, tn+tick.Nanoseconds()) {
+		// another goroutine reset the counter first
+		return c.n.Add(1)
+	}
+	return 1
+}
+
+type counters [_numLevels][_countersPerLevel]counter
+
+func (cs *counters) get(lvl zapcore.Level, msg string) *counter {
+	i := lvl - zapcore.DebugLevel
+	if i < 0 || i >= _numLevels {
+		i = _numLevels - 1
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(msg))
+	return &cs[i][h.Sum32()%_countersPerLevel]
+}
+
+// samplingCore drops repeated entries according to a SamplingConfig, the
+// counters are shared by the cores derived through With.
+type samplingCore struct {
+	zapcore.Core
+	tick     time.Duration
+	rule     SamplingRule
+	levels   map[zapcore.Level]SamplingRule
+	messages map[string]SamplingRule
+	counts   *counters
+}
+
+func newSamplingCore(core zapcore.Core, cfg *SamplingConfig) (zapcore.Core, error) {
+	s := &samplingCore{
+		Core:     core,
+		tick:     cfg.Tick,
+		rule:     cfg.SamplingRule,
+		levels:   make(map[zapcore.Level]SamplingRule, len(cfg.Levels)),
+		messages: cfg.Messages,
+		counts:   &counters{},
+	}
+	if s.tick <= 0 {
+		s.tick = time.Second
+	}
+	for name, rule := range cfg.Levels {
+		var lvl zapcore.Level
+		if err := lvl.UnmarshalText([]byte(name)); err != nil {
+			return nil, err
+		}
+		s.levels[lvl] = rule
+	}
+	return s, nil
+}
+
+func (s *samplingCore) ruleFor(ent zapcore.Entry) SamplingRule {
+	if rule, ok := s.messages[ent.Message]; ok {
+		return rule
+	}
+	if rule, ok := s.levels[ent.Level]; ok {
+		return rule
+	}
+	return s.rule
+}
+
+func (s *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	dup := *s
+	dup.Core = s.Core.With(fields)
+	return &dup
+}
+
+func (s *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !s.Enabled(ent.Level) {
+		return ce
+	}
+	if rule := s.ruleFor(ent); !rule.disabled() {
+		n := s.counts.get(ent.Level, ent.Message).incCheckReset(ent.Time, s.tick)
+		if n > uint64(rule.Initial) &&
+			(rule.Thereafter <= 0 || (n-uint64(rule.Initial))%uint64(rule.Thereafter) != 0) {
+			return ce
+		}
+	}
+	return s.Core.Check(ent, ce)
+}
+
+type RateLimitConfig struct {
+	Level           string        `json:"level" yaml:"level" default:"warn" description:"限流的最低日志级别"`
+	Rate            float64       `json:"rate" yaml:"rate" default:"10" description:"每条消息每秒允许的条数"`
+	Burst           int           `json:"burst" yaml:"burst" default:"20" description:"每条消息允许的突发条数"`
+	SummaryInterval time.Duration `json:"summaryInterval" yaml:"summaryInterval" default:"1m" description:"输出被抑制条数汇总的间隔"`
+}
+
+func (c *RateLimitConfig) UnmarshalJSON(data []byte) error {
+	type plain RateLimitConfig
+	return json.Unmarshal(data, &struct {
+		*plain
+		SummaryInterval *duration `json:"summaryInterval"`
+	}{plain: (*plain)(c), SummaryInterval: (*duration)(&c.SummaryInterval)})
+}
+
+type bucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed uint64
+}
+
+// rateLimiter holds one token bucket per level and message, shared by the
+// cores derived from a rateLimitCore.
+type rateLimiter struct {
+	core  zapcore.Core
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[rateKey]*bucket
+
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+type rateKey struct {
+	level zapcore.Level
+	msg   string
+}
+
+func (r *rateLimiter) allow(ent zapcore.Entry) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := rateKey{ent.Level, ent.Message}
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: ent.Time}
+		r.buckets[key] = b
+	}
+	if elapsed := ent.Time.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * r.rate
+		if b.tokens > r.burst {
+			b.tokens = r.burst
+		}
+		b.last = ent.Time
+	}
+	if b.tokens < 1 {
+		b.suppressed++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// summarize writes one line per message that had entries suppressed since
+// the previous call and forgets the idle buckets.
+func (r *rateLimiter) summarize(now time.Time) {
+	r.mu.Lock()
+	type summary struct {
+		key rateKey
+		n   uint64
+	}
+	var out []summary
+	for key, b := range r.buckets {
+		if b.suppressed > 0 {
+			out = append(out, summary{key, b.suppressed})
+			b.suppressed = 0
+		} else if now.Sub(b.last).Seconds()*r.rate >= r.burst {
+			delete(r.buckets, key)
+		}
+	}
+	r.mu.Unlock()
+	for _, s := range out {
+		ent := zapcore.Entry{Level: s.key.level, Time: now, Message: "suppressed log messages"}
+		_ = r.core.Write(ent, []zapcore.Field{
+			{Key: "message", Type: zapcore.StringType, String: s.key.msg},
+			{Key: "suppressed", Type: zapcore.Uint64Type, Integer: int64(s.n)},
+		})
+	}
+}
+
+func (r *rateLimiter) run(interval time.Duration) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r.summarize(now)
+		case <-r.done:
+			r.summarize(time.Now())
+			return
+		}
+	}
+}
+
+// Close stops the summary goroutine after writing a final summary.
+func (r *rateLimiter) Close() error {
+	select {
+	case <-r.done:
+	default:
+		close(r.done)
+	}
+	<-r.stopped
+	return nil
+}
+
+// rateLimitCore suppresses entries at or above level once the token bucket
+// of their message is empty, a summary of the suppressed entries is logged
+// periodically.
+type rateLimitCore struct {
+	zapcore.Core
+	level   zapcore.Level
+	limiter *rateLimiter
+}
+
+func newRateLimitCore(core zapcore.Core, cfg *RateLimitConfig) (*rateLimitCore, error) {
+	level := zapcore.WarnLevel
+	if cfg.Level != "" {
+		if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+			return nil, err
+		}
+	}
+	r := &rateLimiter{
+		core:    core,
+		rate:    cfg.Rate,
+		burst:   float64(cfg.Burst),
+		buckets: make(map[rateKey]*bucket),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if r.rate <= 0 {
+		r.rate = 10
+	}
+	if r.burst < 1 {
+		r.burst = 1
+	}
+	interval := cfg.SummaryInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go r.run(interval)
+	return &rateLimitCore{Core: core, level: level, limiter: r}, nil
+}
+
+func (c *rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitCore{Core: c.Core.With(fields), level: c.level, limiter: c.limiter}
+}
+
+func (c *rateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(ent.Level) {
+		return ce
+	}
+	if ent.Level >= c.level && !c.limiter.allow(ent) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func writeEntries(core zapcore.Core, lvl zapcore.Level, msg string, n int, at time.Time) {
+	for i := 0; i < n; i++ {
+		ent := zapcore.Entry{Level: lvl, Message: msg, Time: at}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+}
+
+func TestSamplingCore(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	core, err := newSamplingCore(obs, &SamplingConfig{
+		Tick:         time.Second,
+		SamplingRule: SamplingRule{Initial: 2, Thereafter: 5},
+		Levels:       map[string]SamplingRule{"error": {}},
+		Messages:     map[string]SamplingRule{"sql exec detail": {Initial: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	writeEntries(core, zapcore.InfoLevel, "hot", 12, now)
+	writeEntries(core, zapcore.DebugLevel, "sql exec detail", 10, now)
+	writeEntries(core, zapcore.ErrorLevel, "failed", 10, now)
+	writeEntries(core.With(nil), zapcore.InfoLevel, "hot", 1, now.Add(2*time.Second))
+
+	counts := map[string]int{}
+	for _, e := range logs.All() {
+		counts[e.Message]++
+	}
+	// 1, 2, 7, 12 and one more after the tick
+	if counts["hot"] != 5 {
+		t.Errorf("hot logged %d times, want 5", counts["hot"])
+	}
+	if counts["sql exec detail"] != 1 {
+		t.Errorf("sql exec detail logged %d times, want 1", counts["sql exec detail"])
+	}
+	if counts["failed"] != 10 {
+		t.Errorf("failed logged %d times, want all 10", counts["failed"])
+	}
+}
+
+func TestRateLimitCore(t *testing.T) {
+	obs, logs := observer.New(zapcore.DebugLevel)
+	core, err := newRateLimitCore(obs, &RateLimitConfig{Rate: 1, Burst: 3, SummaryInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	writeEntries(core, zapcore.WarnLevel, "slow", 10, now)
+	writeEntries(core, zapcore.InfoLevel, "info", 10, now)
+	writeEntries(core, zapcore.WarnLevel, "slow", 1, now.Add(time.Second))
+	if got := logs.FilterMessage("slow").Len(); got != 4 {
+		t.Errorf("slow logged %d times, want 4", got)
+	}
+	if got := logs.FilterMessage("info").Len(); got != 10 {
+		t.Errorf("info logged %d times, want 10", got)
+	}
+	if err := core.limiter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	summary := logs.FilterMessage("suppressed log messages").All()
+	if len(summary) != 1 {
+		t.Fatalf("got %d summary lines, want 1", len(summary))
+	}
+	if got := summary[0].ContextMap()["suppressed"]; got != uint64(7) {
+		t.Errorf("suppressed = %v, want 7", got)
+	}
+}
@@ -0,0 +1,307 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	SinkFile   = "file"
+	SinkStdout = "stdout"
+	SinkStderr = "stderr"
+	SinkSyslog = "syslog"
+	SinkTCP    = "tcp"
+	SinkUDP    = "udp"
+	SinkWriter = "writer"
+
+	EncodingJSON    = "json"
+	EncodingConsole = "console"
+	EncodingLogfmt  = "logfmt"
+
+	defaultSyslogAddress = "/dev/log"
+
+	netDialTimeout  = time.Second
+	netWriteTimeout = time.Second
+	netMinBackoff   = 100 * time.Millisecond
+	netMaxBackoff   = 30 * time.Second
+)
+
+// SinkConfig describes one output of the logger with its own encoder and
+// minimum level, for example errors as JSON to a dedicated file:
+//
+//	{Type: SinkFile, Prefix: "error", Encoding: EncodingJSON, Level: "error"}
+type SinkConfig struct {
+	Type     string `json:"type" yaml:"type" description:"输出类型: file/stdout/stderr/syslog/tcp/udp/writer"`
+	Encoding string `json:"encoding" yaml:"encoding" description:"编码格式: json/console/logfmt, 为空时按 isJson 决定"`
+	Level    string `json:"level" yaml:"level" description:"该输出的最低日志级别, 为空时使用 logLevel"`
+	Color    bool   `json:"color" yaml:"color" description:"console 编码时是否输出彩色级别"`
+	// Path and Prefix default to the logLocation and logPrefixName of the
+	// logger, they are only used by file sinks.
+	Path   string `json:"path" yaml:"path" description:"file 输出的日志目录"`
+	Prefix string `json:"prefix" yaml:"prefix" description:"file 输出的日志文件前缀名"`
+	// Address is the host:port of tcp/udp sinks or the unix socket of a
+	// syslog sink, which defaults to /dev/log.
+	Address string `json:"address" yaml:"address" description:"tcp/udp/syslog 输出的地址"`
+	Tag     string `json:"tag" yaml:"tag" description:"syslog 的 tag, 为空时使用 moduleName"`
+	// Writer is the destination of a writer sink.
+	Writer io.Writer `json:"-" yaml:"-"`
+}
+
+// sinks returns cfg.Sinks, or the file and stdout sinks described by the
+// legacy logLocation/isStdout/isJson settings when none are configured.
+func (cfg *LoggerConifg) sinks() []SinkConfig {
+	if len(cfg.Sinks) > 0 {
+		return cfg.Sinks
+	}
+	encoding := EncodingConsole
+	if cfg.IsJson {
+		encoding = EncodingJSON
+	}
+	var sinks []SinkConfig
+	if cfg.Location != "" {
+		sinks = append(sinks, SinkConfig{Type: SinkFile, Encoding: encoding, Color: !cfg.IsJson})
+	}
+	if cfg.IsStdout {
+		sinks = append(sinks, SinkConfig{Type: SinkStdout, Encoding: encoding, Color: !cfg.IsJson})
+	}
+	return sinks
+}
+
+// sinkLevel enables the levels enabled by both the shared registry and the
+// minimum level of a sink.
+type sinkLevel struct {
+	*levelRegistry
+	min zapcore.Level
+}
+
+func (l sinkLevel) Enabled(lvl zapcore.Level) bool {
+	return lvl >= l.min && l.levelRegistry.Enabled(lvl)
+}
+
+func (z *zapLogger) sinkCore(cfg *LoggerConifg, sink SinkConfig) (zapcore.Core, error) {
+	encoding := sink.Encoding
+	if encoding == "" {
+		encoding = EncodingConsole
+		if cfg.IsJson {
+			encoding = EncodingJSON
+		}
+	}
+	enc, err := z.encoder(encoding, sink.Color)
+	if err != nil {
+		return nil, err
+	}
+	var level zapcore.LevelEnabler = z.levels
+	if sink.Level != "" {
+		var min zapcore.Level
+		if err := min.UnmarshalText([]byte(sink.Level)); err != nil {
+			return nil, fmt.Errorf("logger: sink %s: %w", sink.Type, err)
+		}
+		level = sinkLevel{levelRegistry: z.levels, min: min}
+	}
+	var ws zapcore.WriteSyncer
+	switch sink.Type {
+	case SinkFile:
+		dir, prefix := sink.Path, sink.Prefix
+		if dir == "" {
+			dir = cfg.Location
+		}
+		if prefix == "" {
+			prefix = z.preName
+		}
+		if ws, err = z.getWriter(cfg, dir, prefix); err != nil {
+			return nil, err
+		}
+	case SinkStdout:
+		ws = zapcore.Lock(zapcore.AddSync(stdWriter{os.Stdout}))
+	case SinkStderr:
+		ws = zapcore.Lock(zapcore.AddSync(stdWriter{os.Stderr}))
+	case SinkTCP, SinkUDP:
+		if sink.Address == "" {
+			return nil, fmt.Errorf("logger: %s sink needs an address", sink.Type)
+		}
+		nw := newNetWriter(sink.Type, sink.Address)
+		z.closer.add(nw)
+		ws = nw
+	case SinkSyslog:
+		address, tag := sink.Address, sink.Tag
+		if address == "" {
+			address = defaultSyslogAddress
+		}
+		if tag == "" {
+			tag = z.name
+		}
+		nw := newNetWriter("unixgram", address)
+		z.closer.add(nw)
+		ws = nw
+		enc = &syslogEncoder{Encoder: enc, tag: tag, pid: os.Getpid()}
+	case SinkWriter:
+		if sink.Writer == nil {
+			return nil, fmt.Errorf("logger: writer sink needs a Writer")
+		}
+		ws = zapcore.Lock(zapcore.AddSync(sink.Writer))
+	default:
+		return nil, fmt.Errorf("logger: unknown sink type %q", sink.Type)
+	}
+	return z.newCore(cfg, enc, ws, level)
+}
+
+func (z *zapLogger) encoder(encoding string, color bool) (zapcore.Encoder, error) {
+	c := zap.NewProductionEncoderConfig()
+	c.EncodeTime = z.timeEncoder
+	c.EncodeDuration = zapcore.SecondsDurationEncoder
+	c.MessageKey = "msg"
+	c.LevelKey = "level"
+	c.TimeKey = "time"
+	c.NameKey = "logger"
+	c.EncodeLevel = zapcore.CapitalLevelEncoder
+	var enc zapcore.Encoder
+	switch encoding {
+	case EncodingJSON:
+		enc = zapcore.NewJSONEncoder(c)
+		enc.AddInt("PID", os.Getpid())
+		enc.AddString("version", z.version)
+	case EncodingConsole:
+		if color {
+			c.EncodeLevel = z.plugin.CapitalColorLevelEncoder
+		}
+		c.EncodeCaller = z.plugin.CustomCallerEncoder
+		enc = zapcore.NewConsoleEncoder(c)
+	case EncodingLogfmt:
+		return newLogfmtEncoder(c), nil
+	default:
+		return nil, fmt.Errorf("logger: unknown encoding %q", encoding)
+	}
+	return &alignEncoder{enc}, nil
+}
+
+// netWriter writes each entry to a tcp, udp or unix datagram connection,
+// dialing lazily and redialing once when a write fails. The dial and the
+// writes are bounded by timeouts, and after a failed dial the entries are
+// dropped with an error until a backoff doubling up to netMaxBackoff has
+// passed, so an unreachable collector does not stall every log call.
+type netWriter struct {
+	network      string
+	address      string
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+}
+
+func newNetWriter(network, address string) *netWriter {
+	return &netWriter{
+		network:      network,
+		address:      address,
+		dialTimeout:  netDialTimeout,
+		writeTimeout: netWriteTimeout,
+		minBackoff:   netMinBackoff,
+		maxBackoff:   netMaxBackoff,
+	}
+}
+
+func (w *netWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var err error
+	for i := 0; i < 2; i++ {
+		if w.conn == nil {
+			if err = w.dial(); err != nil {
+				return 0, err
+			}
+		}
+		var n int
+		if err = w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout)); err == nil {
+			if n, err = w.conn.Write(p); err == nil {
+				return n, nil
+			}
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+	return 0, err
+}
+
+// dial connects w unless a previous dial failed less than the backoff ago.
+func (w *netWriter) dial() error {
+	now := time.Now()
+	if now.Before(w.retryAt) {
+		return fmt.Errorf("logger: %s %s unavailable, retrying in %s", w.network, w.address, w.retryAt.Sub(now).Round(time.Millisecond))
+	}
+	conn, err := net.DialTimeout(w.network, w.address, w.dialTimeout)
+	if err != nil {
+		w.backoff = min(max(2*w.backoff, w.minBackoff), w.maxBackoff)
+		w.retryAt = time.Now().Add(w.backoff)
+		return err
+	}
+	w.conn, w.backoff, w.retryAt = conn, 0, time.Time{}
+	return nil
+}
+
+func (w *netWriter) Sync() error { return nil }
+
+func (w *netWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// syslogEncoder frames every entry as an RFC 3164 message of the user
+// facility for a syslog daemon listening on a local socket.
+type syslogEncoder struct {
+	zapcore.Encoder
+	tag string
+	pid int
+}
+
+func (e *syslogEncoder) Clone() zapcore.Encoder {
+	return &syslogEncoder{Encoder: e.Encoder.Clone(), tag: e.tag, pid: e.pid}
+}
+
+func (e *syslogEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer line.Free()
+	buf := bufferPool.Get()
+	buf.AppendString(fmt.Sprintf("<%d>%s %s[%d]: ", 8+syslogSeverity(ent.Level),
+		ent.Time.Format("Jan _2 15:04:05"), e.tag, e.pid))
+	buf.AppendString(strings.TrimSuffix(line.String(), "\n"))
+	return buf, nil
+}
+
+func syslogSeverity(lvl zapcore.Level) int {
+	switch lvl {
+	case zapcore.DebugLevel:
+		return 7
+	case zapcore.InfoLevel:
+		return 6
+	case zapcore.WarnLevel:
+		return 4
+	case zapcore.ErrorLevel:
+		return 3
+	case zapcore.DPanicLevel, zapcore.PanicLevel:
+		return 2
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,100 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSinks(t *testing.T) {
+	var errOut, debugOut bytes.Buffer
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	l, err := New(&LoggerConifg{
+		LogLevel: 5,
+		Sinks: []SinkConfig{
+			{Type: SinkWriter, Writer: &errOut, Encoding: EncodingJSON, Level: "error"},
+			{Type: SinkWriter, Writer: &debugOut, Encoding: EncodingLogfmt},
+			{Type: SinkUDP, Address: pc.LocalAddr().String(), Encoding: EncodingJSON, Level: "warn"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	l.Debug(ctx, "debug line", "user", "bob smith")
+	l.Error(ctx, "error line", errors.New("boom"))
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := errOut.String(); strings.Contains(s, "debug line") || !strings.Contains(s, `"msg":"error line`) {
+		t.Errorf("error sink = %s", s)
+	}
+	if s := debugOut.String(); !strings.Contains(s, `user="bob smith"`) || !strings.Contains(s, "level=error") {
+		t.Errorf("logfmt sink = %s", s)
+	}
+
+	buf := make([]byte, 4096)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(buf[:n]); !strings.Contains(s, "error line") {
+		t.Errorf("udp sink = %s", s)
+	}
+}
+
+func TestUnknownSink(t *testing.T) {
+	if _, err := New(&LoggerConifg{Sinks: []SinkConfig{{Type: "kafka"}}}); err == nil {
+		t.Fatal("expected an error for an unknown sink type")
+	}
+}
+
+func TestNetWriterBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	w := newNetWriter(SinkTCP, address)
+	w.minBackoff, w.maxBackoff = 200*time.Millisecond, time.Second
+	defer w.Close()
+	if _, err := w.Write([]byte("lost\n")); err == nil {
+		t.Fatal("write to a closed port succeeded")
+	}
+	ln, err = net.Listen("tcp", address)
+	if err != nil {
+		t.Skipf("port taken again: %v", err)
+	}
+	defer ln.Close()
+	// the collector is back, but the writer waits for the backoff
+	if _, err := w.Write([]byte("dropped\n")); err == nil || !strings.Contains(err.Error(), "retrying in") {
+		t.Fatalf("write during the backoff = %v", err)
+	}
+	time.Sleep(250 * time.Millisecond)
+	if _, err := w.Write([]byte("kept\n")); err != nil {
+		t.Fatalf("write after the backoff = %v", err)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "kept\n" {
+		t.Errorf("received %q, %v", line, err)
+	}
+}
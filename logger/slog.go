@@ -0,0 +1,257 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func toSlogLevel(lvl zapcore.Level) slog.Level {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case lvl == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case lvl == zapcore.WarnLevel:
+		return slog.LevelWarn
+	case lvl == zapcore.ErrorLevel:
+		return slog.LevelError
+	default:
+		return slog.LevelError + slog.Level(lvl-zapcore.ErrorLevel)*4
+	}
+}
+
+func fromSlogLevel(lvl slog.Level) zapcore.Level {
+	switch {
+	case lvl < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case lvl < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case lvl < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+type slogHandler struct {
+	l      Log
+	prefix string
+}
+
+var _ slog.Handler = (*slogHandler)(nil)
+
+// NewSlogHandler returns a slog.Handler writing through l, so that records
+// logged with log/slog get the imcontext values of their context and end up
+// in the outputs of l. Groups are flattened into dotted keys.
+func NewSlogHandler(l Log) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return fromSlogLevel(lvl) >= h.l.GetLevel()
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	kv := make([]any, 0, 2*r.NumAttrs())
+	var err error
+	r.Attrs(func(a slog.Attr) bool {
+		if e, ok := a.Value.Resolve().Any().(error); ok && err == nil && h.prefix == "" &&
+			(a.Key == "err" || a.Key == "error") {
+			err = e
+			return true
+		}
+		kv = appendAttr(kv, h.prefix, a)
+		return true
+	})
+	lvl := fromSlogLevel(r.Level)
+	// attribute the entry to the slog call rather than to this handler
+	if cl, ok := h.l.(callerLogger); ok && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		caller := zapcore.EntryCaller{
+			Defined:  true,
+			PC:       frame.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+		cl.logAt(ctx, caller, lvl, r.Message, err, kv)
+		return nil
+	}
+	switch lvl {
+	case zapcore.DebugLevel:
+		h.l.Debug(ctx, r.Message, kv...)
+	case zapcore.InfoLevel:
+		h.l.Info(ctx, r.Message, kv...)
+	case zapcore.WarnLevel:
+		h.l.Warn(ctx, r.Message, err, kv...)
+	default:
+		h.l.Error(ctx, r.Message, err, kv...)
+	}
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	var kv []any
+	for _, a := range attrs {
+		kv = appendAttr(kv, h.prefix, a)
+	}
+	return &slogHandler{l: h.l.WithValues(kv...), prefix: h.prefix}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{l: h.l, prefix: h.prefix + name + "."}
+}
+
+func appendAttr(kv []any, prefix string, a slog.Attr) []any {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kv
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			kv = appendAttr(kv, prefix, ga)
+		}
+		return kv
+	}
+	return append(kv, prefix+a.Key, a.Value.Any())
+}
+
+type slogLogger struct {
+	h      slog.Handler
+	levels *levelRegistry
+	named  string
+	depth  int
+}
+
+var _ Log = (*slogLogger)(nil)
+
+// NewSlogLogger returns a Log writing to h. Its initial level is the lowest
+// level h is enabled for, SetLevel can raise it further.
+func NewSlogLogger(h slog.Handler) Log {
+	level := zapcore.ErrorLevel
+	for lvl := zapcore.DebugLevel; lvl < zapcore.ErrorLevel; lvl++ {
+		if h.Enabled(context.Background(), toSlogLevel(lvl)) {
+			level = lvl
+			break
+		}
+	}
+	return &slogLogger{h: h, levels: newLevelRegistry(level)}
+}
+
+func (s *slogLogger) log(ctx context.Context, lvl zapcore.Level, msg string, err error, fields []any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !s.levels.enabledFor(s.named, lvl) || !s.h.Enabled(ctx, toSlogLevel(lvl)) {
+		return
+	}
+	if err != nil {
+		errEnc, _ := newErrorEncoding(nil)
+		fields = append(fields, errEnc.fields(lvl, err)...)
+	}
+	var pcs [1]uintptr
+	// skip runtime.Callers, log and the Log method
+	runtime.Callers(3+s.depth, pcs[:])
+	r := slog.NewRecord(time.Now(), toSlogLevel(lvl), msg, pcs[0])
+	if s.named != "" {
+		r.AddAttrs(slog.String("logger", s.named))
+	}
+	r.Add(appendContext(ctx, fields)...)
+	_ = s.h.Handle(ctx, r)
+}
+
+func (s *slogLogger) Debug(ctx context.Context, msg string, fields ...any) {
+	s.log(ctx, zapcore.DebugLevel, msg, nil, fields)
+}
+
+func (s *slogLogger) Info(ctx context.Context, msg string, fields ...any) {
+	s.log(ctx, zapcore.InfoLevel, msg, nil, fields)
+}
+
+func (s *slogLogger) Warn(ctx context.Context, msg string, err error, fields ...any) {
+	s.log(ctx, zapcore.WarnLevel, msg, err, fields)
+}
+
+func (s *slogLogger) Error(ctx context.Context, msg string, err error, fields ...any) {
+	s.log(ctx, zapcore.ErrorLevel, msg, err, fields)
+}
+
+// DPanic logs at DPanicLevel, a slog backed logger has no development mode
+// and never panics here.
+func (s *slogLogger) DPanic(ctx context.Context, msg string, err error, fields ...any) {
+	s.log(ctx, zapcore.DPanicLevel, msg, err, fields)
+}
+
+func (s *slogLogger) Panic(ctx context.Context, msg string, err error, fields ...any) {
+	s.log(ctx, zapcore.PanicLevel, msg, err, fields)
+	panic(msg)
+}
+
+func (s *slogLogger) Fatal(ctx context.Context, msg string, err error, fields ...any) {
+	s.log(ctx, zapcore.FatalLevel, msg, err, fields)
+	exit(1)
+}
+
+func (s *slogLogger) WithValues(fields ...any) Log {
+	dup := *s
+	dup.h = s.h.WithAttrs(argsToAttrs(fields))
+	return &dup
+}
+
+func (s *slogLogger) WithName(name string) Log {
+	dup := *s
+	dup.named = joinName(s.named, name)
+	return &dup
+}
+
+func (s *slogLogger) WithDepth(depth int) Log {
+	dup := *s
+	dup.depth += depth
+	return &dup
+}
+
+func (s *slogLogger) SetLevel(level zapcore.Level) {
+	s.levels.setLevel(s.named, level)
+}
+
+func (s *slogLogger) GetLevel() zapcore.Level {
+	return s.levels.level(s.named)
+}
+
+func (s *slogLogger) Sync() error { return nil }
+
+func (s *slogLogger) Close() error { return nil }
+
+// argsToAttrs converts alternating keys and values to attributes the way
+// slog.Logger does, a value without a string key gets the key "!BADKEY".
+func argsToAttrs(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	for len(args) > 0 {
+		switch x := args[0].(type) {
+		case slog.Attr:
+			attrs, args = append(attrs, x), args[1:]
+		case string:
+			if len(args) == 1 {
+				attrs, args = append(attrs, slog.String("!BADKEY", x)), nil
+			} else {
+				attrs, args = append(attrs, slog.Any(x, args[1])), args[2:]
+			}
+		default:
+			attrs, args = append(attrs, slog.Any("!BADKEY", x)), args[1:]
+		}
+	}
+	return attrs
+}
@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"testing"
+
+	"github.com/yunbaifan/pkg/imcontext"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSlogHandler(t *testing.T) {
+	l, logs := newObservedLogger(zapcore.InfoLevel)
+	sl := slog.New(NewSlogHandler(l)).With("component", "sync").WithGroup("req")
+	ctx := imcontext.WithOperation(context.Background(), "op-1")
+
+	sl.DebugContext(ctx, "hidden")
+	sl.ErrorContext(ctx, "failed", "err", errors.New("boom"), slog.Group("user", "id", 7))
+
+	if logs.Len() != 1 {
+		t.Fatalf("logged %d entries, want 1", logs.Len())
+	}
+	fields := logs.All()[0].ContextMap()
+	want := map[string]any{
+		"component":   "sync",
+		"req.err":     "boom",
+		"req.user.id": int64(7),
+		"operationID": "op-1",
+	}
+	for k, v := range want {
+		if got := fields[k]; got != v {
+			t.Errorf("%s = %#v, want %#v", k, got, v)
+		}
+	}
+}
+
+func TestSlogHandlerCaller(t *testing.T) {
+	var out bytes.Buffer
+	l, err := New(&LoggerConifg{
+		LogLevel: LevelInfo,
+		Sinks:    []SinkConfig{{Type: SinkWriter, Writer: &out, Encoding: EncodingJSON}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	sl := slog.New(NewSlogHandler(l))
+
+	sl.Info("here") // the line reported as caller
+	_, _, line, _ := runtime.Caller(0)
+	var entry map[string]any
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if want := fmt.Sprintf("logger/slog_test.go:%d", line-1); entry["caller"] != want {
+		t.Errorf("caller = %v, want %s", entry["caller"], want)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewSlogLogger(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	if l.GetLevel() != zapcore.InfoLevel {
+		t.Fatalf("level = %v, want info", l.GetLevel())
+	}
+	ctx := imcontext.WithOpUserID(context.Background(), "u-1")
+	l.Debug(ctx, "hidden")
+	l.WithName("gorm").WithValues("table", "users").Error(ctx, "query failed", errors.New("timeout"))
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	want := map[string]any{
+		"msg":      "query failed",
+		"level":    "ERROR",
+		"logger":   "gorm",
+		"table":    "users",
+		"opUserID": "u-1",
+		"error":    "timeout",
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+}
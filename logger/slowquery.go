@@ -0,0 +1,399 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SlowQueryConfig configures a SlowQueryRecorder.
+type SlowQueryConfig struct {
+	Window     time.Duration `json:"window" yaml:"window" default:"1m" description:"慢查询汇总的时间窗口"`
+	TopN       int           `json:"topN" yaml:"topN" default:"10" description:"每个窗口输出的慢查询条数"`
+	MaxSamples int           `json:"maxSamples" yaml:"maxSamples" default:"1000" description:"每个窗口每种查询保留的耗时样本数"`
+	// ExplainPrefix is prepended to the statement to get its plan, for
+	// example "EXPLAIN QUERY PLAN" for SQLite.
+	ExplainPrefix  string        `json:"explainPrefix" yaml:"explainPrefix" default:"EXPLAIN" description:"获取执行计划的语句前缀"`
+	ExplainTimeout time.Duration `json:"explainTimeout" yaml:"explainTimeout" default:"5s" description:"获取执行计划的超时时间"`
+	// DB runs the EXPLAIN statements, the db the recorder is used with when
+	// nil.
+	DB *gorm.DB `json:"-" yaml:"-"`
+	// Logger receives the summaries and plans, the global logger when nil.
+	Logger Log `json:"-" yaml:"-"`
+}
+
+func (c *SlowQueryConfig) UnmarshalJSON(data []byte) error {
+	type plain SlowQueryConfig
+	return json.Unmarshal(data, &struct {
+		*plain
+		Window         *duration `json:"window"`
+		ExplainTimeout *duration `json:"explainTimeout"`
+	}{plain: (*plain)(c), Window: (*duration)(&c.Window), ExplainTimeout: (*duration)(&c.ExplainTimeout)})
+}
+
+// SlowQuery is the aggregate of the slow queries of one fingerprint.
+type SlowQuery struct {
+	Fingerprint string
+	SQL         string // the latest statement
+	Operation   string
+	Table       string
+	Count       int
+	Total       time.Duration
+	P50         time.Duration
+	P99         time.Duration
+	Max         time.Duration
+}
+
+type slowQueryStats struct {
+	sql     string
+	count   int
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+}
+
+// SlowQueryRecorder aggregates slow queries by fingerprint and logs the
+// top N of every window. Use WithSlowQueryRecorder to feed it from the gorm
+// logger.
+//
+// Used as a gorm.Plugin it also logs the plan of the first slow SELECT of
+// each fingerprint, slow meaning above the SlowThreshold of the gorm logger
+// of the db. The plan is queried with the statement and its parameters as
+// bind arguments.
+type SlowQueryRecorder struct {
+	cfg SlowQueryConfig
+
+	mu    sync.Mutex
+	stats map[string]*slowQueryStats
+	seen  map[string]struct{}
+
+	explains chan slowQueryExplain
+	done     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+var _ gorm.Plugin = (*SlowQueryRecorder)(nil)
+
+type slowQueryExplain struct {
+	fingerprint, sql string
+	vars             []any
+}
+
+const slowQueryStartKey = "logger:slow_query_start"
+
+// maxSeenFingerprints bounds the fingerprints remembered to run EXPLAIN only
+// once per fingerprint.
+const maxSeenFingerprints = 10000
+
+func NewSlowQueryRecorder(cfg SlowQueryConfig) *SlowQueryRecorder {
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.TopN <= 0 {
+		cfg.TopN = 10
+	}
+	if cfg.MaxSamples <= 0 {
+		cfg.MaxSamples = 1000
+	}
+	if cfg.ExplainPrefix == "" {
+		cfg.ExplainPrefix = "EXPLAIN"
+	}
+	if cfg.ExplainTimeout <= 0 {
+		cfg.ExplainTimeout = 5 * time.Second
+	}
+	r := &SlowQueryRecorder{
+		cfg:      cfg,
+		stats:    make(map[string]*slowQueryStats),
+		seen:     make(map[string]struct{}),
+		explains: make(chan slowQueryExplain, 64),
+		done:     make(chan struct{}),
+	}
+	r.wg.Add(2)
+	go r.run()
+	go r.explainLoop()
+	return r
+}
+
+func (r *SlowQueryRecorder) logger() Log {
+	if r.cfg.Logger != nil {
+		return r.cfg.Logger
+	}
+	return FromContext(context.Background())
+}
+
+// Record adds a slow statement to the current window.
+func (r *SlowQueryRecorder) Record(sql string, elapsed time.Duration) {
+	fp := Fingerprint(sql)
+	r.mu.Lock()
+	s, ok := r.stats[fp]
+	if !ok {
+		s = &slowQueryStats{}
+		r.stats[fp] = s
+	}
+	s.sql = sql
+	s.count++
+	s.total += elapsed
+	if elapsed > s.max {
+		s.max = elapsed
+	}
+	if len(s.samples) < r.cfg.MaxSamples {
+		s.samples = append(s.samples, elapsed)
+	}
+	r.mu.Unlock()
+}
+
+func (r *SlowQueryRecorder) Name() string {
+	return "logger:slow_query"
+}
+
+func (r *SlowQueryRecorder) Initialize(db *gorm.DB) error {
+	if r.cfg.DB == nil {
+		r.cfg.DB = db
+	}
+	cb := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after func(name string, fn func(*gorm.DB)) error
+	}{
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, h := range hooks {
+		if err := h.before("logger:slow_query_before_"+h.operation, r.before); err != nil {
+			return err
+		}
+		if err := h.after("logger:slow_query_after_"+h.operation, r.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *SlowQueryRecorder) before(db *gorm.DB) {
+	db.InstanceSet(slowQueryStartKey, time.Now())
+}
+
+// after queues the EXPLAIN of a slow SELECT seen for the first time, with the
+// statement as built by gorm and its parameters.
+func (r *SlowQueryRecorder) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(slowQueryStartKey)
+	if !ok || db.Error != nil || db.DryRun {
+		return
+	}
+	l, ok := db.Logger.(*ormLogger)
+	if !ok || l.SlowThreshold == 0 || time.Since(v.(time.Time)) <= l.SlowThreshold {
+		return
+	}
+	sql := db.Statement.SQL.String()
+	if op, _ := parseSQL(sql); op != "SELECT" {
+		return
+	}
+	fp := Fingerprint(sql)
+	r.mu.Lock()
+	_, seen := r.seen[fp]
+	if !seen {
+		if len(r.seen) >= maxSeenFingerprints {
+			r.seen = make(map[string]struct{})
+		}
+		r.seen[fp] = struct{}{}
+	}
+	r.mu.Unlock()
+	if seen {
+		return
+	}
+	vars := append([]any(nil), db.Statement.Vars...)
+	select {
+	case r.explains <- slowQueryExplain{fingerprint: fp, sql: sql, vars: vars}:
+	default:
+	}
+}
+
+// Top returns the top N fingerprints of the current window ordered by their
+// total time.
+func (r *SlowQueryRecorder) Top() []SlowQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.top()
+}
+
+func (r *SlowQueryRecorder) top() []SlowQuery {
+	top := make([]SlowQuery, 0, len(r.stats))
+	for fp, s := range r.stats {
+		sort.Slice(s.samples, func(i, j int) bool { return s.samples[i] < s.samples[j] })
+		op, table := parseSQL(s.sql)
+		top = append(top, SlowQuery{
+			Fingerprint: fp,
+			SQL:         s.sql,
+			Operation:   op,
+			Table:       table,
+			Count:       s.count,
+			Total:       s.total,
+			P50:         percentile(s.samples, 0.5),
+			P99:         percentile(s.samples, 0.99),
+			Max:         s.max,
+		})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Total != top[j].Total {
+			return top[i].Total > top[j].Total
+		}
+		return top[i].Fingerprint < top[j].Fingerprint
+	})
+	if len(top) > r.cfg.TopN {
+		top = top[:r.cfg.TopN]
+	}
+	return top
+}
+
+// percentile returns the nearest-rank percentile p of the sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// Flush logs the top N of the current window and starts a new one.
+func (r *SlowQueryRecorder) Flush() {
+	r.mu.Lock()
+	top := r.top()
+	r.stats = make(map[string]*slowQueryStats)
+	r.mu.Unlock()
+
+	l := r.logger()
+	ctx := context.Background()
+	for i, q := range top {
+		l.Warn(ctx, "slow sql summary", nil,
+			"rank", i+1,
+			"fingerprint", q.Fingerprint,
+			"sql", q.SQL,
+			"operation", q.Operation,
+			"table", q.Table,
+			"count", q.Count,
+			"p50_ms", durationMillis(q.P50),
+			"p99_ms", durationMillis(q.P99),
+			"max_ms", durationMillis(q.Max),
+			"window", r.cfg.Window.String())
+	}
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d.Nanoseconds()) / 1e6
+}
+
+// Explain runs the EXPLAIN statement of the SELECT statement sql, with its
+// placeholders bound to vars, and logs the plan. Other statements are not
+// explained, since some databases execute them to get their plan.
+func (r *SlowQueryRecorder) Explain(fingerprint, sql string, vars ...any) error {
+	if op, _ := parseSQL(sql); r.cfg.DB == nil || op != "SELECT" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.ExplainTimeout)
+	defer cancel()
+	// straight to the pool, the plan query must be neither rebuilt by gorm
+	// nor traced
+	rows, err := r.cfg.DB.ConnPool.QueryContext(ctx, r.cfg.ExplainPrefix+" "+sql, vars...)
+	if err != nil {
+		r.logger().Warn(ctx, "slow sql explain failed", err, "fingerprint", fingerprint, "sql", sql)
+		return err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	var plan []map[string]any
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		row := make(map[string]any, len(cols))
+		for i, c := range cols {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[c] = values[i]
+		}
+		plan = append(plan, row)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	r.logger().Warn(ctx, "slow sql explain", nil, "fingerprint", fingerprint, "sql", sql, "plan", plan)
+	return nil
+}
+
+func (r *SlowQueryRecorder) run() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.cfg.Window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// explainLoop runs the queued EXPLAIN statements apart from the flushes, a
+// slow plan query must not delay them.
+func (r *SlowQueryRecorder) explainLoop() {
+	defer r.wg.Done()
+	for {
+		select {
+		case e := <-r.explains:
+			_ = r.Explain(e.fingerprint, e.sql, e.vars...)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// Close stops the recorder and logs the last window.
+func (r *SlowQueryRecorder) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+		r.wg.Wait()
+		r.Flush()
+	})
+	return nil
+}
+
+var (
+	sqlSpaces    = regexp.MustCompile(`\s+`)
+	sqlValueList = regexp.MustCompile(`\(\s*\?(?:\s*,\s*\?)*\s*\)`)
+	sqlTuples    = regexp.MustCompile(`\(\?\+\)(?:\s*,\s*\(\?\+\))+`)
+)
+
+// Fingerprint normalizes sql so that statements differing only in their
+// literals, the length of their IN lists or the number of inserted rows
+// share the same fingerprint.
+func Fingerprint(sql string) string {
+	fp := strings.ToLower(strings.TrimSpace(RedactSQL(sql)))
+	fp = sqlSpaces.ReplaceAllString(fp, " ")
+	fp = sqlValueList.ReplaceAllString(fp, "(?+)")
+	fp = sqlTuples.ReplaceAllString(fp, "(?+)")
+	return strings.TrimRight(fp, "; ")
+}
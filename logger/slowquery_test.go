@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+func TestFingerprint(t *testing.T) {
+	a := Fingerprint("SELECT * FROM users WHERE id IN (1, 2, 3) AND name = 'bob'")
+	b := Fingerprint("select *  from users\n where id in (7) and name = 'alice' ;")
+	if a != b || a != "select * from users where id in (?+) and name = ?" {
+		t.Errorf("fingerprints = %q, %q", a, b)
+	}
+	a = Fingerprint("INSERT INTO t (a,b) VALUES (1,'x'),(2,'y')")
+	b = Fingerprint("INSERT INTO t (a,b) VALUES (3,'z')")
+	if a != b {
+		t.Errorf("insert fingerprints = %q, %q", a, b)
+	}
+	// string values as rendered by the SQLite and MySQL dialectors
+	a = Fingerprint("SELECT * FROM `users` WHERE email = \"alice@example.com\" AND token = \"s3cr3t\"")
+	b = Fingerprint("SELECT * FROM `users` WHERE email = \"bob@example.com\" AND token = \"x\"\"y\"")
+	if a != b || a != "select * from `users` where email = ? and token = ?" {
+		t.Errorf("double-quoted fingerprints = %q, %q", a, b)
+	}
+}
+
+func TestSlowQueryRecorder(t *testing.T) {
+	zl, logs := newObservedLogger(zapcore.DebugLevel)
+	r := NewSlowQueryRecorder(SlowQueryConfig{Window: time.Hour, TopN: 1, Logger: zl})
+	defer r.Close()
+
+	ctx := IntoContext(context.Background(), zl)
+	l := NewGormLogger(WithLogLevel(gormLogger.Warn), WithSlowQueryRecorder(r))
+	for i, d := range []time.Duration{10, 20, 30, 40, 1000} {
+		sql := "SELECT * FROM users WHERE name = 'u" + string(rune('a'+i)) + "'"
+		l.Trace(ctx, time.Now().Add(-d*time.Millisecond-100*time.Millisecond), func() (string, int64) { return sql, 0 }, nil)
+	}
+	r.Record("UPDATE users SET name = 'x' WHERE id = 1", 150*time.Millisecond)
+
+	top := r.Top()
+	if len(top) != 1 || top[0].Count != 5 || top[0].Table != "users" || top[0].Operation != "SELECT" {
+		t.Fatalf("top = %+v", top)
+	}
+	if top[0].P50 < 130*time.Millisecond || top[0].P50 > 200*time.Millisecond || top[0].P99 < time.Second {
+		t.Errorf("p50 = %s, p99 = %s", top[0].P50, top[0].P99)
+	}
+
+	r.Flush()
+	summary := logs.FilterMessage("slow sql summary").All()
+	if len(summary) != 1 || summary[0].ContextMap()["count"] != int64(5) {
+		t.Fatalf("summary = %v", summary)
+	}
+	if len(r.Top()) != 0 {
+		t.Errorf("window not reset after flush")
+	}
+}
+
+func TestSlowQueryRecorderExplain(t *testing.T) {
+	zl, logs := newObservedLogger(zapcore.DebugLevel)
+	r := NewSlowQueryRecorder(SlowQueryConfig{Window: time.Hour, ExplainPrefix: "EXPLAIN QUERY PLAN", Logger: zl})
+	defer r.Close()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{
+		Logger: NewGormLogger(WithLogger(zl), WithLogLevel(gormLogger.Warn), WithSlowThreshold(time.Nanosecond),
+			WithSlowQueryRecorder(r)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // every connection has its own in-memory database
+	if err := db.AutoMigrate(&optionUser{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"bob", "alice"} {
+		db.Where("name = ? AND id > ?", name, 0).Find(&[]optionUser{})
+	}
+	db.Model(&optionUser{}).Where("id = ?", 1).Update("name", "x")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for logs.FilterMessage("slow sql explain").Len() < 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	explains := logs.FilterMessage("slow sql explain").All()
+	if len(explains) != 1 {
+		t.Fatalf("logged %d plans, want one for the SELECT fingerprint", len(explains))
+	}
+	fields := explains[0].ContextMap()
+	if sql, _ := fields["sql"].(string); !strings.Contains(sql, "name = ? AND id > ?") {
+		t.Errorf("explained sql = %q, want the statement with its placeholders", sql)
+	}
+	if plan, ok := fields["plan"].([]map[string]any); !ok || len(plan) == 0 {
+		t.Errorf("plan = %#v", fields["plan"])
+	}
+	if failed := logs.FilterMessage("slow sql explain failed").Len(); failed != 0 {
+		t.Errorf("%d explains failed", failed)
+	}
+}
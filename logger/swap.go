@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// retireTimeout bounds how long a replaced generation waits for its pending
+// writes, an entry that was checked but never written would hold it forever.
+const retireTimeout = 5 * time.Second
+
+type coreGeneration struct {
+	gen    uint64
+	core   zapcore.Core
+	async  []*AsyncWriter
+	errors errorEncoding
+	writes *writeGuard
+}
+
+// writeGuard counts the entries checked against a generation and not yet
+// written, so that its outputs are only closed once they are idle.
+type writeGuard struct {
+	pending atomic.Int64
+	retired atomic.Bool
+}
+
+func (w *writeGuard) release() {
+	w.pending.Add(-1)
+}
+
+// retire makes the following acquires move to the next generation and waits
+// for the pending writes, at most timeout.
+func (w *writeGuard) retire(timeout time.Duration) {
+	w.retired.Store(true)
+	deadline := time.Now().Add(timeout)
+	for w.pending.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// swapState holds the core currently used by a logger and all loggers
+// derived from it, Reconfigure replaces it without rebuilding those loggers.
+type swapState struct {
+	gen     atomic.Uint64
+	current atomic.Pointer[coreGeneration]
+}
+
+// store makes g the current generation and returns the one it replaces.
+func (s *swapState) store(g coreGeneration) *coreGeneration {
+	g.gen = s.gen.Add(1)
+	g.writes = &writeGuard{}
+	return s.current.Swap(&g)
+}
+
+func (s *swapState) load() *coreGeneration {
+	return s.current.Load()
+}
+
+// acquire returns the current generation, which is kept open until release
+// is called on its writes.
+func (s *swapState) acquire() *coreGeneration {
+	for {
+		g := s.load()
+		g.writes.pending.Add(1)
+		if !g.writes.retired.Load() {
+			return g
+		}
+		// replaced between the load and the count, the next load returns
+		// its successor
+		g.writes.release()
+	}
+}
+
+// swapCore delegates to the current core of its state, replaying the fields
+// added through With whenever the core has been swapped.
+type swapCore struct {
+	state  *swapState
+	fields []zapcore.Field
+	cache  atomic.Pointer[coreGeneration]
+}
+
+var _ zapcore.Core = (*swapCore)(nil)
+
+func (c *swapCore) current() zapcore.Core {
+	return c.core(c.state.load())
+}
+
+func (c *swapCore) core(g *coreGeneration) zapcore.Core {
+	if len(c.fields) == 0 {
+		return g.core
+	}
+	if cached := c.cache.Load(); cached != nil && cached.gen == g.gen {
+		return cached.core
+	}
+	core := g.core.With(c.fields)
+	c.cache.Store(&coreGeneration{gen: g.gen, core: core})
+	return core
+}
+
+func (c *swapCore) Enabled(lvl zapcore.Level) bool {
+	return c.current().Enabled(lvl)
+}
+
+func (c *swapCore) With(fields []zapcore.Field) zapcore.Core {
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(all, c.fields...)
+	all = append(all, fields...)
+	return &swapCore{state: c.state, fields: all}
+}
+
+// Check checks ent against the current generation and keeps that generation
+// open until the entry is written.
+func (c *swapCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	g := c.state.acquire()
+	checked := c.core(g).Check(ent, nil)
+	if checked == nil {
+		g.writes.release()
+		return ce
+	}
+	return ce.AddCore(ent, &pendingWrite{checked: checked, writes: g.writes})
+}
+
+func (c *swapCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	g := c.state.acquire()
+	defer g.writes.release()
+	return c.core(g).Write(ent, fields)
+}
+
+func (c *swapCore) Sync() error {
+	g := c.state.acquire()
+	defer g.writes.release()
+	return c.core(g).Sync()
+}
+
+// pendingWrite writes an entry checked against a generation to the cores of
+// that generation and then releases it.
+type pendingWrite struct {
+	checked *zapcore.CheckedEntry
+	writes  *writeGuard
+}
+
+func (p *pendingWrite) Enabled(zapcore.Level) bool { return true }
+
+func (p *pendingWrite) With([]zapcore.Field) zapcore.Core { return p }
+
+func (p *pendingWrite) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, p)
+}
+
+func (p *pendingWrite) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	defer p.writes.release()
+	// the caller may have changed the entry since it was checked, e.g. its
+	// Caller
+	p.checked.Entry = ent
+	p.checked.Write(fields...)
+	return nil
+}
+
+func (p *pendingWrite) Sync() error { return nil }
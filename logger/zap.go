@@ -5,17 +5,29 @@ import (
 )
 
 func Debug(ctx context.Context, msg string, fields ...any) {
-	Logger.Debug(ctx, msg, fields...)
+	FromContext(ctx).Debug(ctx, msg, fields...)
 }
 
 func Info(ctx context.Context, msg string, fields ...any) {
-	Logger.Info(ctx, msg, fields...)
+	FromContext(ctx).Info(ctx, msg, fields...)
 }
 
 func Warn(ctx context.Context, msg string, err error, fields ...any) {
-	Logger.Warn(ctx, msg, err, fields...)
+	FromContext(ctx).Warn(ctx, msg, err, fields...)
 }
 
 func Error(ctx context.Context, msg string, err error, fields ...any) {
-	Logger.Error(ctx, msg, err, fields...)
+	FromContext(ctx).Error(ctx, msg, err, fields...)
+}
+
+func DPanic(ctx context.Context, msg string, err error, fields ...any) {
+	FromContext(ctx).DPanic(ctx, msg, err, fields...)
+}
+
+func Panic(ctx context.Context, msg string, err error, fields ...any) {
+	FromContext(ctx).Panic(ctx, msg, err, fields...)
+}
+
+func Fatal(ctx context.Context, msg string, err error, fields ...any) {
+	FromContext(ctx).Fatal(ctx, msg, err, fields...)
 }
@@ -0,0 +1,176 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// gRPC status codes, with the values of google.golang.org/grpc/codes.
+const (
+	GRPCOK                 uint32 = 0
+	GRPCCanceled           uint32 = 1
+	GRPCUnknown            uint32 = 2
+	GRPCInvalidArgument    uint32 = 3
+	GRPCDeadlineExceeded   uint32 = 4
+	GRPCNotFound           uint32 = 5
+	GRPCAlreadyExists      uint32 = 6
+	GRPCPermissionDenied   uint32 = 7
+	GRPCResourceExhausted  uint32 = 8
+	GRPCFailedPrecondition uint32 = 9
+	GRPCAborted            uint32 = 10
+	GRPCOutOfRange         uint32 = 11
+	GRPCUnimplemented      uint32 = 12
+	GRPCInternal           uint32 = 13
+	GRPCUnavailable        uint32 = 14
+	GRPCDataLoss           uint32 = 15
+	GRPCUnauthenticated    uint32 = 16
+)
+
+// CodeError is an error with a stable numeric code for API clients, a
+// message, an optional detail and the HTTP and gRPC statuses it maps to.
+// Two CodeErrors are equal for errors.Is when their codes are equal, so
+// errors.Is(err, ErrArgs) holds for ErrArgs.WithDetail("...") and for the
+// errors wrapping it with Warp.
+type CodeError struct {
+	code       int
+	msg        string
+	detail     string
+	httpStatus int
+	grpcCode   uint32
+}
+
+// NewCodeError returns a CodeError mapped to http.StatusInternalServerError
+// and GRPCUnknown, use Register for the codes shared with clients.
+func NewCodeError(code int, msg string) *CodeError {
+	return &CodeError{code: code, msg: msg, httpStatus: http.StatusInternalServerError, grpcCode: GRPCUnknown}
+}
+
+func (e *CodeError) Code() int        { return e.code }
+func (e *CodeError) Msg() string      { return e.msg }
+func (e *CodeError) Detail() string   { return e.detail }
+func (e *CodeError) HTTPStatus() int  { return e.httpStatus }
+func (e *CodeError) GRPCCode() uint32 { return e.grpcCode }
+
+func (e *CodeError) Error() string {
+	if e.detail == "" {
+		return e.msg
+	}
+	return e.msg + ": " + e.detail
+}
+
+// WithDetail returns a copy of e with the detail set.
+func (e *CodeError) WithDetail(detail string) *CodeError {
+	dup := *e
+	dup.detail = detail
+	return &dup
+}
+
+// WithDetailf returns a copy of e with the detail formatted from format and
+// args.
+func (e *CodeError) WithDetailf(format string, args ...any) *CodeError {
+	return e.WithDetail(fmt.Sprintf(format, args...))
+}
+
+// Fields returns the code as a key/value pair, so that it is logged as a
+// field of the errors wrapping e.
+func (e *CodeError) Fields() []any {
+	return []any{"code", e.code}
+}
+
+func (e *CodeError) Is(target error) bool {
+	t, ok := target.(*CodeError)
+	return ok && t != nil && t.code == e.code
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[int]*CodeError)
+)
+
+// Register adds a code to the registry and returns its CodeError. It panics
+// when the code is already registered, codes must stay stable.
+func Register(code int, msg string, httpStatus int, grpcCode uint32) *CodeError {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[code]; ok {
+		panic(fmt.Sprintf("errs: code %d already registered", code))
+	}
+	e := &CodeError{code: code, msg: msg, httpStatus: httpStatus, grpcCode: grpcCode}
+	registry[code] = e
+	return e
+}
+
+// Lookup returns the registered CodeError of code.
+func Lookup(code int) (*CodeError, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	e, ok := registry[code]
+	return e, ok
+}
+
+// Predefined error codes shared with clients.
+var (
+	ErrInternalServer = Register(500, "ServerInternalError", http.StatusInternalServerError, GRPCInternal)
+	ErrArgs           = Register(1001, "ArgsError", http.StatusBadRequest, GRPCInvalidArgument)
+	ErrNoPermission   = Register(1002, "NoPermissionError", http.StatusForbidden, GRPCPermissionDenied)
+	ErrDuplicateKey   = Register(1003, "DuplicateKeyError", http.StatusConflict, GRPCAlreadyExists)
+	ErrRecordNotFound = Register(1004, "RecordNotFoundError", http.StatusNotFound, GRPCNotFound)
+	ErrTooManyRequest = Register(1005, "TooManyRequestError", http.StatusTooManyRequests, GRPCResourceExhausted)
+	ErrUnavailable    = Register(1006, "ServiceUnavailableError", http.StatusServiceUnavailable, GRPCUnavailable)
+
+	ErrTokenExpired     = Register(1501, "TokenExpiredError", http.StatusUnauthorized, GRPCUnauthenticated)
+	ErrTokenInvalid     = Register(1502, "TokenInvalidError", http.StatusUnauthorized, GRPCUnauthenticated)
+	ErrTokenMalformed   = Register(1503, "TokenMalformedError", http.StatusUnauthorized, GRPCUnauthenticated)
+	ErrTokenNotExist    = Register(1504, "TokenNotExistError", http.StatusUnauthorized, GRPCUnauthenticated)
+	ErrTokenKicked      = Register(1505, "TokenKickedError", http.StatusUnauthorized, GRPCUnauthenticated)
+	ErrUserIDNotFound   = Register(1101, "UserIDNotFoundError", http.StatusNotFound, GRPCNotFound)
+	ErrGroupIDNotFound  = Register(1201, "GroupIDNotFoundError", http.StatusNotFound, GRPCNotFound)
+	ErrNotInGroup       = Register(1202, "NotInGroupYetError", http.StatusForbidden, GRPCFailedPrecondition)
+	ErrMessageHasRead   = Register(1301, "MessageHasReadDisable", http.StatusConflict, GRPCFailedPrecondition)
+	ErrConnOverMaxLimit = Register(1601, "ConnOverMaxNumLimit", http.StatusTooManyRequests, GRPCResourceExhausted)
+)
+
+// AsCodeError returns the first CodeError in the chain of err.
+func AsCodeError(err error) (*CodeError, bool) {
+	var ce *CodeError
+	if errors.As(err, &ce) {
+		return ce, true
+	}
+	return nil, false
+}
+
+// Code returns the code of the CodeError in the chain of err, 0 for a nil
+// err and the code of ErrInternalServer for an error without code.
+func Code(err error) int {
+	if err == nil {
+		return 0
+	}
+	if ce, ok := AsCodeError(err); ok {
+		return ce.code
+	}
+	return ErrInternalServer.code
+}
+
+// HTTPStatus returns the HTTP status of err, http.StatusOK for a nil err.
+func HTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if ce, ok := AsCodeError(err); ok {
+		return ce.httpStatus
+	}
+	return ErrInternalServer.httpStatus
+}
+
+// GRPCCode returns the gRPC status code of err, GRPCOK for a nil err.
+func GRPCCode(err error) uint32 {
+	if err == nil {
+		return GRPCOK
+	}
+	if ce, ok := AsCodeError(err); ok {
+		return ce.grpcCode
+	}
+	return ErrInternalServer.grpcCode
+}
@@ -0,0 +1,38 @@
+package errs
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestCodeError(t *testing.T) {
+	err := Warp(ErrRecordNotFound.WithDetail("user 42"), "get user", "userID", 42)
+	if !errors.Is(err, ErrRecordNotFound) || errors.Is(err, ErrArgs) {
+		t.Errorf("errors.Is mismatch for %v", err)
+	}
+	var ce *CodeError
+	if !errors.As(err, &ce) || ce.Detail() != "user 42" {
+		t.Fatalf("errors.As = %v", ce)
+	}
+	if Code(err) != 1004 || HTTPStatus(err) != http.StatusNotFound || GRPCCode(err) != GRPCNotFound {
+		t.Errorf("code = %d, http = %d, grpc = %d", Code(err), HTTPStatus(err), GRPCCode(err))
+	}
+	if ErrRecordNotFound.Detail() != "" {
+		t.Errorf("WithDetail modified the registered error")
+	}
+
+	plain := errors.New("boom")
+	if Code(plain) != ErrInternalServer.Code() || HTTPStatus(nil) != http.StatusOK || Code(nil) != 0 {
+		t.Errorf("code of plain error = %d", Code(plain))
+	}
+	if e, ok := Lookup(1001); !ok || e != ErrArgs {
+		t.Errorf("Lookup(1001) = %v, %v", e, ok)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Errorf("registering a duplicate code did not panic")
+		}
+	}()
+	Register(1001, "Again", http.StatusBadRequest, GRPCInvalidArgument)
+}
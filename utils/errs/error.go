@@ -1,13 +1,104 @@
 package errs
 
 import (
+	"fmt"
+	"io"
+
 	"github.com/pkg/errors"
 	stringutil "github.com/yunbaifan/pkg/utils/strings"
 )
 
+// Warp adds msg and kv as context and a stack trace to err. The result
+// unwraps to err, so the code of a CodeError in err is kept, and kv stays
+// available through Fields.
 func Warp(err error, msg string, kv ...any) error {
 	if err == nil {
 		return nil
 	}
-	return errors.WithStack(errors.WithMessage(err, stringutil.ToString(msg, kv)))
+	return errors.WithStack(&withFields{cause: err, msg: msg, kv: kv})
+}
+
+// withFields is an error with a message and key/value pairs describing
+// where it happened.
+type withFields struct {
+	cause error
+	msg   string
+	kv    []any
+}
+
+func (w *withFields) Error() string {
+	if w.msg == "" {
+		return w.cause.Error()
+	}
+	return w.msg + ": " + w.cause.Error()
+}
+
+func (w *withFields) Unwrap() error { return w.cause }
+
+func (w *withFields) Cause() error { return w.cause }
+
+// Fields returns the key/value pairs of w only, Fields(err) collects them
+// along the whole chain.
+func (w *withFields) Fields() []any { return w.kv }
+
+func (w *withFields) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			fmt.Fprintf(s, "%+v\n", w.cause)
+			io.WriteString(s, stringutil.ToString(w.msg, w.kv))
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, w.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", w.Error())
+	}
+}
+
+type fielder interface {
+	Fields() []any
+}
+
+// Fields returns the key/value pairs of every error in the chain of err,
+// from the outermost to the innermost. The pairs of each error are
+// normalized on their own, a key that is not a string is formatted with
+// fmt.Sprint and a key without value gets "Missing value" as ToString does,
+// so an odd number of arguments to Warp does not shift the other pairs.
+func Fields(err error) []any {
+	var kv []any
+	for cur := err; cur != nil; cur = Next(cur) {
+		f, ok := cur.(fielder)
+		if !ok {
+			continue
+		}
+		fields := f.Fields()
+		for i := 0; i < len(fields); i += 2 {
+			key, ok := fields[i].(string)
+			if !ok {
+				key = fmt.Sprint(fields[i])
+			}
+			var value any = "Missing value"
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+			kv = append(kv, key, value)
+		}
+	}
+	return kv
+}
+
+// Next returns the error wrapped by err, following both errors.Unwrap and
+// the Cause method of pkg/errors, nil at the end of the chain.
+func Next(err error) error {
+	if next := errors.Unwrap(err); next != nil {
+		return next
+	}
+	if c, ok := err.(interface{ Cause() error }); ok {
+		if next := c.Cause(); next != err {
+			return next
+		}
+	}
+	return nil
 }
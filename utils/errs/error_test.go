@@ -0,0 +1,57 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWarpFields(t *testing.T) {
+	root := ErrArgs.WithDetail("empty name")
+	err := Warp(Warp(root, "validate", "field", "name"), "create user", "uid", 7)
+	if got, want := err.Error(), "create user: validate: ArgsError: empty name"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if got, want := Fields(err), []any{"uid", 7, "field", "name", "code", 1001}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %v, want %v", got, want)
+	}
+	if !errors.Is(err, ErrArgs) {
+		t.Errorf("wrapped error lost its code")
+	}
+	if s := fmt.Sprintf("%+v", err); !strings.Contains(s, "create user, uid=7") || !strings.Contains(s, "TestWarpFields") {
+		t.Errorf("%%+v = %s", s)
+	}
+
+	if got := Warp(errors.New("eof"), "read").Error(); got != "read: eof" {
+		t.Errorf("Warp without kv = %q", got)
+	}
+	if Fields(errors.New("plain")) != nil || Warp(nil, "nothing") != nil {
+		t.Errorf("unexpected fields or error")
+	}
+}
+
+func TestFieldsOddKeyValues(t *testing.T) {
+	err := Warp(Warp(errors.New("eof"), "a", "x", 1), "b", "k")
+	if got, want := Fields(err), []any{"k", "Missing value", "x", 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %v, want %v", got, want)
+	}
+	err = Warp(errors.New("eof"), "c", 3, "three")
+	if got, want := Fields(err), []any{"3", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields = %v, want %v", got, want)
+	}
+}
+
+func TestNext(t *testing.T) {
+	root := errors.New("eof")
+	wrapped := Warp(root, "read")
+	// the stack of pkg/errors is unwrapped first, then the cause
+	var chain []error
+	for cur := error(wrapped); cur != nil; cur = Next(cur) {
+		chain = append(chain, cur)
+	}
+	if len(chain) != 3 || chain[len(chain)-1] != root {
+		t.Errorf("chain = %v", chain)
+	}
+}
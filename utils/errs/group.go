@@ -0,0 +1,148 @@
+package errs
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Group collects the errors of a batch operation. It is safe for concurrent
+// use, errors.Is and errors.As look into every member.
+type Group struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Append adds the non-nil errors of errs, the members of a Group are added
+// one by one. Appending g to itself does nothing.
+func (g *Group) Append(errs ...error) {
+	// the members of another Group are read under its lock, before g is locked
+	var flat []error
+	for _, err := range errs {
+		switch e := err.(type) {
+		case nil:
+		case *Group:
+			if e != g {
+				flat = append(flat, e.Errors()...)
+			}
+		default:
+			flat = append(flat, err)
+		}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.errs = append(g.errs, flat...)
+}
+
+// AppendIndex adds err annotated with the index of the item it is about.
+func (g *Group) AppendIndex(index int, err error) {
+	if err != nil {
+		g.Append(&itemError{err: err, label: "[" + strconv.Itoa(index) + "]", field: "index", item: index})
+	}
+}
+
+// AppendKey adds err annotated with the key of the item it is about.
+func (g *Group) AppendKey(key any, err error) {
+	if err != nil {
+		g.Append(&itemError{err: err, label: fmt.Sprintf("[%v]", key), field: "key", item: key})
+	}
+}
+
+// Len returns the number of errors in g.
+func (g *Group) Len() int {
+	if g == nil {
+		return 0
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.errs)
+}
+
+// Errors returns a copy of the errors in g.
+func (g *Group) Errors() []error {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]error(nil), g.errs...)
+}
+
+// ErrorOrNil returns g, or nil when g holds no error.
+func (g *Group) ErrorOrNil() error {
+	if g.Len() == 0 {
+		return nil
+	}
+	return g
+}
+
+func (g *Group) Unwrap() []error {
+	return g.Errors()
+}
+
+// Error returns the message of the only error in g, or one line per error.
+func (g *Group) Error() string {
+	errs := g.Errors()
+	if len(errs) == 1 {
+		return errs[0].Error()
+	}
+	return g.format(errs, "%s")
+}
+
+func (g *Group) format(errs []error, verb string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d errors occurred:", len(errs))
+	for _, err := range errs {
+		b.WriteString("\n\t* ")
+		b.WriteString(strings.ReplaceAll(fmt.Sprintf(verb, err), "\n", "\n\t  "))
+	}
+	return b.String()
+}
+
+func (g *Group) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, g.format(g.Errors(), "%+v"))
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, g.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", g.Error())
+	}
+}
+
+// Join returns the non-nil errors of errs as a Group, or nil when there is
+// none.
+func Join(errs ...error) error {
+	g := &Group{}
+	g.Append(errs...)
+	return g.ErrorOrNil()
+}
+
+// itemError is an error about one item of a batch, identified by its index
+// or key.
+type itemError struct {
+	err   error
+	label string
+	field string
+	item  any
+}
+
+func (e *itemError) Error() string { return e.label + " " + e.err.Error() }
+
+func (e *itemError) Unwrap() error { return e.err }
+
+func (e *itemError) Fields() []any { return []any{e.field, e.item} }
+
+func (e *itemError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		fmt.Fprintf(s, "%s %+v", e.label, e.err)
+		return
+	}
+	io.WriteString(s, e.Error())
+}
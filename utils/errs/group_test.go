@@ -0,0 +1,90 @@
+package errs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	var g Group
+	if g.ErrorOrNil() != nil {
+		t.Fatal("empty group is not nil")
+	}
+	g.Append(nil, io.EOF)
+	g.AppendIndex(3, Warp(ErrRecordNotFound, "load row", "id", 9))
+	g.AppendKey("alice", &fs.PathError{Op: "open", Path: "a.txt", Err: fs.ErrNotExist})
+	g.AppendKey("bob", nil)
+
+	err := g.ErrorOrNil()
+	if err == nil || g.Len() != 3 {
+		t.Fatalf("group holds %d errors", g.Len())
+	}
+	if !errors.Is(err, io.EOF) || !errors.Is(err, ErrRecordNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("errors.Is does not see every member of %v", err)
+	}
+	var pathErr *fs.PathError
+	if !errors.As(err, &pathErr) || pathErr.Path != "a.txt" {
+		t.Errorf("errors.As = %v", pathErr)
+	}
+	want := "3 errors occurred:\n\t* EOF\n\t* [3] load row: RecordNotFoundError\n\t* [alice] open a.txt: file does not exist"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+	if got := Fields(g.Errors()[1]); !reflect.DeepEqual(got, []any{"index", 3, "id", 9, "code", 1004}) {
+		t.Errorf("item fields = %v", got)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	if Join(nil, nil) != nil {
+		t.Error("Join of nil errors is not nil")
+	}
+	inner := Join(io.EOF, io.ErrClosedPipe)
+	err := Join(inner, io.ErrShortWrite)
+	var g *Group
+	if !errors.As(err, &g) || g.Len() != 3 {
+		t.Fatalf("nested group not flattened: %v", err)
+	}
+	if got := Join(io.EOF).Error(); got != "EOF" {
+		t.Errorf("single error = %q", got)
+	}
+
+	var wg sync.WaitGroup
+	var batch Group
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch.AppendIndex(i, io.EOF)
+		}(i)
+	}
+	wg.Wait()
+	if batch.Len() != 50 || strings.Count(batch.Error(), "\n") != 50 {
+		t.Errorf("concurrent appends = %d", batch.Len())
+	}
+}
+
+func TestGroupAppendGroup(t *testing.T) {
+	var g, other Group
+	g.Append(io.EOF)
+	other.Append(io.ErrUnexpectedEOF)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		g.Append(&g, &other)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("appending a group to itself deadlocks")
+	}
+	if got := g.Errors(); !reflect.DeepEqual(got, []error{io.EOF, io.ErrUnexpectedEOF}) {
+		t.Errorf("Errors = %v", got)
+	}
+}
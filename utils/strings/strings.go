@@ -18,23 +18,21 @@ func FormatString(text string, length int, alignLeft bool) string {
 	return fmt.Sprintf(formatStr, text)
 }
 
+// ToString formats s followed by the key/value pairs of kv, as in
+// "msg, key=value, key2=value2".
 func ToString(s string, kv []any) string {
 	var buf bytes.Buffer
-	switch {
-	case len(kv) == 0:
-	default:
-		buf.WriteString(s)
-		for i := 0; i < len(kv); i += 2 {
-			if buf.Len() > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(fmt.Sprintf("%v", kv[i]))
-			buf.WriteString("=")
-			if j := i + 1; j < len(kv) {
-				buf.WriteString(fmt.Sprintf("%v", kv[j]))
-			} else {
-				buf.WriteString("Missing value")
-			}
+	buf.WriteString(s)
+	for i := 0; i < len(kv); i += 2 {
+		if buf.Len() > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(fmt.Sprintf("%v", kv[i]))
+		buf.WriteString("=")
+		if j := i + 1; j < len(kv) {
+			buf.WriteString(fmt.Sprintf("%v", kv[j]))
+		} else {
+			buf.WriteString("Missing value")
 		}
 	}
 	return buf.String()